@@ -1,10 +1,8 @@
 package acl
 
 import (
-	"reflect"
 	"time"
 
-	"github.com/huandu/go-sqlbuilder"
 	"github.com/zicare/go-rpg/db"
 	"github.com/zicare/go-rpg/lib"
 	"github.com/zicare/go-rpg/msg"
@@ -34,7 +32,6 @@ func Init(m db.Model) (err error) {
 
 	var (
 		f [5]string
-		t = reflect.Indirect(reflect.ValueOf(m))
 
 		g Grant
 
@@ -45,25 +42,18 @@ func Init(m db.Model) (err error) {
 		to     time.Time
 
 		now = time.Now()
-		sb  = sqlbuilder.PostgreSQL.NewSelectBuilder()
+		sb  = db.Flavor().NewSelectBuilder()
 	)
 
-	for i := 0; i < t.NumField(); i++ {
-		if tag, ok := t.Type().Field(i).Tag.Lookup("acl"); ok {
-			if col, ok := t.Type().Field(i).Tag.Lookup("db"); ok {
-				switch tag {
-				case "role":
-					f[0] = col
-				case "route":
-					f[1] = col
-				case "method":
-					f[2] = col
-				case "from":
-					f[3] = col
-				case "to":
-					f[4] = col
-				}
-			}
+	if err = db.Register(m); err != nil {
+		return
+	}
+
+	fields, _ := db.Fields(m)
+
+	for i, tag := range []string{"role", "route", "method", "from", "to"} {
+		if cols := fields.Tagged("acl", tag); len(cols) == 1 {
+			f[i] = cols[0]
 		}
 	}
 
@@ -81,9 +71,16 @@ func Init(m db.Model) (err error) {
 		sb.GreaterThan(f[4], now),
 	)
 
+	ctx, cancel := db.Context(nil, m)
+	defer cancel()
+
 	sql, args := sb.Build()
 	//log.Println(sql, args)
-	rows, err := db.Db().Query(sql, args...)
+	rows, err := db.Db().QueryContext(ctx, sql, args...)
+	if err != nil {
+		//Server error: %s
+		return msg.Get("25").SetArgs(err).M2E()
+	}
 	defer rows.Close()
 
 	//scan rows
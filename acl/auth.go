@@ -7,7 +7,6 @@ import (
 	"github.com/zicare/go-rpg/msg"
 
 	"github.com/gin-gonic/gin"
-	sqlbuilder "github.com/huandu/go-sqlbuilder"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/zicare/go-rpg/config"
@@ -45,7 +44,7 @@ func BasicAuth(m User) gin.HandlerFunc {
 			now       = time.Now()
 			table     = m.View()
 			fields, _ = db.Fields(m)
-			ms        = sqlbuilder.NewStruct(m).For(sqlbuilder.PostgreSQL)
+			ms        = db.Struct(m)
 			sb        = ms.SelectFrom(table)
 			pepper    = config.Config().GetString("pepper")
 		)
@@ -56,9 +55,12 @@ func BasicAuth(m User) gin.HandlerFunc {
 			//sb.Equal("password", password),
 		)
 
+		ctx, cancel := db.Context(c, m)
+		defer cancel()
+
 		sql, args := sb.Build()
 		//fmt.Println(sql, args)
-		err := db.Db().QueryRow(sql, args...).Scan(ms.Addr(&m)...)
+		err := db.Db().QueryRowContext(ctx, sql, args...).Scan(ms.Addr(&m)...)
 		if err != nil {
 			//email not registered
 			abort(c, 401, msg.Get("4")) //Invalid credentials
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	createTable = regexp.MustCompile(`(?is)^CREATE\s+(?:(?:GLOBAL|LOCAL)\s+)?(?:(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)\s*\(`)
+	alterTable  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?(\S+)\s+(.*)$`)
+	addPrimary  = regexp.MustCompile(`(?is)^ADD\s+(?:CONSTRAINT\s+\S+\s+)?PRIMARY\s+KEY\s*\(([^)]*)\)`)
+	setDefault  = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?(\S+)\s+(SET\s+DEFAULT\s+.*|ADD\s+GENERATED\s+.*)$`)
+	tablePK     = regexp.MustCompile(`(?is)^(?:CONSTRAINT\s+\S+\s+)?PRIMARY\s+KEY\s*\(([^)]*)\)`)
+	tableCons   = regexp.MustCompile(`(?is)^(?:CONSTRAINT|UNIQUE|CHECK|FOREIGN|EXCLUDE|LIKE)\b`)
+	colCons     = regexp.MustCompile(`(?is)\s+(?:NOT\s+NULL|NULL|DEFAULT|PRIMARY\s+KEY|REFERENCES|UNIQUE|CHECK|CONSTRAINT|COLLATE|GENERATED)\b`)
+	notNull     = regexp.MustCompile(`(?is)\bNOT\s+NULL\b`)
+	primaryKey  = regexp.MustCompile(`(?is)\bPRIMARY\s+KEY\b`)
+	defaults    = regexp.MustCompile(`(?is)\b(?:DEFAULT|GENERATED)\b`)
+	sequence    = regexp.MustCompile(`(?is)\bnextval\s*\(|\bAS\s+IDENTITY\b`)
+	serialType  = regexp.MustCompile(`(?i)^(?:small|big)?serial[248]?$`)
+)
+
+//parseDDL returns the tables created by the postgres statements in src,
+//i.e. a pg_dump --schema-only output, with the primary keys and defaults
+//set later by ALTER TABLE. Views aren't read, their columns aren't known
+//without the db.
+func parseDDL(src string, schema string) []*table {
+
+	var (
+		tables []*table
+		byName = make(map[string]*table)
+	)
+
+	for _, stmt := range statements(src) {
+
+		if m := createTable.FindStringSubmatchIndex(stmt); m != nil {
+			var (
+				t    = &table{Name: unqualify(stmt[m[2]:m[3]], schema)}
+				body = enclosed(stmt[m[1]-1:])
+			)
+			for _, def := range split(body) {
+				define(t, def)
+			}
+			tables = append(tables, t)
+			byName[t.Name] = t
+			continue
+		}
+
+		m := alterTable.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		t, ok := byName[unqualify(m[1], schema)]
+		if !ok {
+			continue
+		}
+		if pk := addPrimary.FindStringSubmatch(m[2]); pk != nil {
+			primary(t, pk[1])
+		} else if d := setDefault.FindStringSubmatch(m[2]); d != nil {
+			if c := t.col(unquote(d[1])); c != nil {
+				c.Default = true
+				c.Serial = c.Serial || sequence.MatchString(d[2])
+			}
+		}
+	}
+	return tables
+}
+
+//define adds to t the column or the primary key defined by def,
+//an element of a CREATE TABLE
+func define(t *table, def string) {
+
+	if m := tablePK.FindStringSubmatch(def); m != nil {
+		primary(t, m[1])
+		return
+	} else if def == "" || tableCons.MatchString(def) {
+		return
+	}
+
+	var (
+		name = strings.Fields(def)[0]
+		typ  string
+		cons string
+	)
+
+	if strings.HasPrefix(def, `"`) {
+		name = def[:quoted(def, `"`)]
+	}
+	typ = strings.TrimSpace(def[len(name):])
+	if loc := colCons.FindStringIndex(typ); loc != nil {
+		typ, cons = strings.TrimSpace(typ[:loc[0]]), typ[loc[0]:]
+	}
+
+	c := column{
+		Name:    unquote(name),
+		Type:    strings.ToLower(typ),
+		Primary: primaryKey.MatchString(cons),
+		Default: defaults.MatchString(cons),
+		Serial:  serialType.MatchString(typ) || sequence.MatchString(cons),
+	}
+	c.Nullable = !c.Primary && !notNull.MatchString(cons)
+	c.Default = c.Default || c.Serial
+
+	t.Columns = append(t.Columns, c)
+}
+
+//primary flags the columns listed in cols as primary
+func primary(t *table, cols string) {
+
+	for _, name := range strings.Split(cols, ",") {
+		if c := t.col(unquote(strings.TrimSpace(name))); c != nil {
+			c.Primary, c.Nullable = true, false
+		}
+	}
+}
+
+//statements splits src into statements, leaving comments out.
+//Semicolons in quotes, dollar quotes included, don't end statements.
+func statements(src string) (stmts []string) {
+
+	var b strings.Builder
+
+	for i := 0; i < len(src); i++ {
+		switch {
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			b.WriteByte(' ')
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end + 3
+			}
+			b.WriteByte(' ')
+		case src[i] == '\'' || src[i] == '"':
+			end := quoted(src[i:], src[i:i+1])
+			b.WriteString(src[i : i+end])
+			i += end - 1
+		case src[i] == '$' && dollarTag.MatchString(src[i:]):
+			tag := dollarTag.FindString(src[i:])
+			end := strings.Index(src[i+len(tag):], tag)
+			if end < 0 {
+				end = len(src) - i - len(tag)
+			} else {
+				end += len(tag)
+			}
+			b.WriteString(src[i : i+len(tag)+end])
+			i += len(tag) + end - 1
+		case src[i] == ';':
+			if s := strings.TrimSpace(b.String()); s != "" {
+				stmts = append(stmts, s)
+			}
+			b.Reset()
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+	if s := strings.TrimSpace(b.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return
+}
+
+//$$ or $tag$
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+//quoted returns the length of the quoted string s starts with,
+//doubled quotes escape them
+func quoted(s string, q string) int {
+
+	for i := 1; i < len(s); i++ {
+		if s[i:i+1] == q {
+			if i+1 < len(s) && s[i+1:i+2] == q {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+//enclosed returns what's between the parenthesis s starts with
+//and its matching one
+func enclosed(s string) string {
+
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			i += quoted(s[i:], s[i:i+1]) - 1
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return s[1:i]
+			}
+		}
+	}
+	return strings.TrimPrefix(s, "(")
+}
+
+//split splits s at the commas not enclosed in parenthesis or quotes
+func split(s string) (parts []string) {
+
+	var depth, start int
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			i += quoted(s[i:], s[i:i+1]) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if p := strings.TrimSpace(s[start:]); p != "" {
+		parts = append(parts, p)
+	}
+	return
+}
+
+//unquote returns the identifier s unquoted
+func unquote(s string) string {
+	return strings.Trim(s, `"`)
+}
+
+//unqualify returns the name of a table, qualified by its schema
+//unless it's the schema given
+func unqualify(name string, schema string) string {
+
+	parts := strings.Split(name, ".")
+	for i := range parts {
+		parts[i] = unquote(parts[i])
+	}
+	if len(parts) == 2 && parts[0] == schema {
+		return parts[1]
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+//summary prints tables as name(column type flags, ...) lines
+func summary(tables []*table) string {
+
+	var lines []string
+	for _, t := range tables {
+		var cols []string
+		for _, c := range t.Columns {
+			s := c.Name + " " + c.Type
+			for _, f := range []struct {
+				on   bool
+				flag string
+			}{{c.Primary, "pk"}, {c.Serial, "serial"}, {c.Default, "default"}, {c.Nullable, "null"}} {
+				if f.on {
+					s += " " + f.flag
+				}
+			}
+			cols = append(cols, s)
+		}
+		lines = append(lines, fmt.Sprintf("%s(%s)", t.Name, strings.Join(cols, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestParseDDL(t *testing.T) {
+
+	for _, tc := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"inline constraints",
+			`CREATE TABLE items (
+				id serial PRIMARY KEY,
+				name varchar(100) NOT NULL,
+				price numeric(10, 2) DEFAULT 0,
+				note text
+			);`,
+			"items(id serial pk serial default, name varchar(100), price numeric(10, 2) default null, note text null)",
+		},
+		{
+			"pg_dump",
+			`CREATE TABLE public.orders (
+				id integer NOT NULL,
+				placed timestamp with time zone
+			);
+			ALTER TABLE ONLY public.orders ALTER COLUMN id SET DEFAULT nextval('public.orders_id_seq'::regclass);
+			ALTER TABLE ONLY public.orders
+				ADD CONSTRAINT orders_pkey PRIMARY KEY (id);`,
+			"orders(id integer pk serial default, placed timestamp with time zone null)",
+		},
+		{
+			"quotes, comments and table constraints",
+			`-- tags; not a statement
+			CREATE TABLE IF NOT EXISTS "Tags" (
+				"Name" varchar(20) DEFAULT 'a;b, c',
+				owner_id bigint REFERENCES users (id),
+				/* a; comment */
+				CONSTRAINT tags_pk PRIMARY KEY ("Name", owner_id),
+				UNIQUE ("Name"),
+				CHECK (length("Name") > 0)
+			);`,
+			"Tags(Name varchar(20) pk default, owner_id bigint pk)",
+		},
+		{
+			"other schemas and statements",
+			`CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN; END; $$ LANGUAGE plpgsql;
+			CREATE TABLE audit.logs (id bigint PRIMARY KEY);
+			ALTER TABLE ONLY missing ADD CONSTRAINT missing_pkey PRIMARY KEY (id);
+			CREATE INDEX logs_id ON audit.logs (id);`,
+			"audit.logs(id bigint pk)",
+		},
+	} {
+		if got := summary(parseDDL(tc.src, "public")); got != tc.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tc.name, got, tc.want)
+		}
+	}
+}
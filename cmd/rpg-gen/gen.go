@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strings"
+	"text/template"
+)
+
+//header marks the files rpg-gen may overwrite
+const header = "// Code generated by rpg-gen. DO NOT EDIT.\n"
+
+//model is a table as the templates take it
+type model struct {
+	Pkg     string
+	Type    string
+	Recv    string
+	Table   string
+	View    string
+	Path    string
+	Fields  []field
+	Keys    []field
+	Imports []string
+	Parsed  bool
+}
+
+//field is a column as the templates take it
+type field struct {
+	Name   string
+	Col    string
+	GoType string
+	Tag    string
+	Parse  string
+}
+
+//initialisms are kept upper case in Go names
+var initialisms = map[string]bool{
+	"api": true, "id": true, "ip": true, "json": true, "sql": true,
+	"uid": true, "url": true, "uri": true, "uuid": true, "http": true,
+}
+
+//camel returns s, a snake case name, in camel case
+func camel(s string) string {
+
+	var b strings.Builder
+
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == ' '
+	}) {
+		if initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+		} else {
+			b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+		}
+	}
+	if s := b.String(); s != "" && (s[0] < '0' || s[0] > '9') {
+		return s
+	}
+	return "X" + b.String()
+}
+
+//singular returns the singular of the english plural s, naively
+func singular(s string) string {
+
+	switch {
+	case strings.HasSuffix(s, "ies"):
+		return s[:len(s)-3] + "y"
+	case strings.HasSuffix(s, "sses"), strings.HasSuffix(s, "xes"):
+		return s[:len(s)-2]
+	case strings.HasSuffix(s, "ss"), strings.HasSuffix(s, "us"):
+		return s
+	case strings.HasSuffix(s, "s"):
+		return s[:len(s)-1]
+	}
+	return s
+}
+
+//receiver returns the receiver name of the type named, the type name
+//in lower camel case unless it clashes with a keyword or an identifier
+//the generated methods use
+func receiver(name string) string {
+
+	r := strings.ToLower(name[:1]) + name[1:]
+	switch r {
+	case "c", "e", "v", "b", "p", "sl", "err", "pIDs", "db", "lib", "msg", "gin",
+		"rest", "sqlbuilder", "strconv", "time", "validation", "validator":
+		return "m"
+	}
+	if token.IsKeyword(r) {
+		return "m"
+	}
+	return r
+}
+
+//goType returns the type of the fields holding columns of the sql type
+func goType(sqlType string) string {
+
+	t := strings.ToLower(sqlType)
+	if strings.HasSuffix(t, "]") || strings.HasPrefix(t, "_") {
+		//arrays as the db prints them, i.e. {1,2}
+		return "*string"
+	}
+
+	switch strings.FieldsFunc(t, func(r rune) bool { return r == ' ' || r == '(' })[0] {
+	case "smallint", "integer", "int", "int2", "int4", "int8", "bigint",
+		"smallserial", "serial", "serial2", "serial4", "serial8", "bigserial":
+		return "*int64"
+	case "real", "float4", "float8", "float", "double", "numeric", "decimal":
+		return "*float64"
+	case "boolean", "bool":
+		return "*bool"
+	case "timestamp", "timestamptz", "date", "time", "timetz":
+		return "*time.Time"
+	case "bytea":
+		return "[]byte"
+	}
+	return "*string"
+}
+
+//parse returns the call parsing p.B, a primary key value, into
+//the value fields of type goType point to, none for strings
+func parse(goType string) string {
+
+	switch goType {
+	case "*int64":
+		return "strconv.ParseInt(p.B.(string), 10, 64)"
+	case "*float64":
+		return "strconv.ParseFloat(p.B.(string), 64)"
+	case "*bool":
+		return "strconv.ParseBool(p.B.(string))"
+	case "*time.Time":
+		return "time.Parse(time.RFC3339, p.B.(string))"
+	}
+	return ""
+}
+
+//binding returns the binding tag of c
+func binding(c column) string {
+
+	var tags []string
+
+	switch {
+	case c.View:
+		return "-"
+	case c.Serial:
+		//set by the db only
+		tags = append(tags, "omitempty", "auto")
+	case c.Primary || c.Nullable || c.Default:
+		tags = append(tags, "omitempty")
+	default:
+		tags = append(tags, "required")
+	}
+	if t := strings.ToLower(c.Type); t == "json" || t == "jsonb" {
+		tags = append(tags, "json")
+	}
+	return strings.Join(tags, ",")
+}
+
+//newModel returns the model of t
+func newModel(t *table, pkg string) model {
+
+	var (
+		name = t.Name[strings.LastIndex(t.Name, ".")+1:]
+		m    = model{
+			Pkg:   pkg,
+			Type:  camel(singular(name)),
+			Table: t.Name,
+			View:  t.View,
+			Path:  "/" + strings.Replace(name, "_", "-", -1),
+		}
+		parts = make([][]string, len(t.Columns))
+		width = make([]int, 2)
+		uses  = map[string]bool{}
+	)
+
+	m.Recv = receiver(m.Type)
+	if m.View == "" {
+		m.View = m.Table
+	}
+
+	//tags are aligned, as in the db package example
+	for i, c := range t.Columns {
+		parts[i] = []string{
+			fmt.Sprintf(`db:"%s"`, c.Name),
+			fmt.Sprintf(`json:"%s"`, c.Name),
+			fmt.Sprintf(`binding:"%s"`, binding(c)),
+		}
+		if c.Serial {
+			parts[i][1] = fmt.Sprintf(`json:"%s,omitempty"`, c.Name)
+		}
+		if c.Primary {
+			parts[i] = append(parts[i], `primary:"1"`)
+		}
+		if c.Serial {
+			parts[i] = append(parts[i], `serial:"1"`)
+		}
+		if c.View {
+			parts[i] = append(parts[i], `view:"1"`)
+		}
+		for j := range width {
+			if len(parts[i][j]) > width[j] {
+				width[j] = len(parts[i][j])
+			}
+		}
+	}
+
+	for i, c := range t.Columns {
+		for j := range width {
+			parts[i][j] += strings.Repeat(" ", width[j]-len(parts[i][j]))
+		}
+		f := field{
+			Name:   camel(c.Name),
+			Col:    c.Name,
+			GoType: goType(c.Type),
+			Tag:    strings.TrimSpace(strings.Join(parts[i], " ")),
+		}
+		m.Fields = append(m.Fields, f)
+		if c.Primary {
+			f.Parse = parse(f.GoType)
+			m.Keys = append(m.Keys, f)
+			m.Parsed = m.Parsed || f.Parse != ""
+		}
+		uses["time"] = uses["time"] || f.GoType == "*time.Time"
+		uses["strconv"] = uses["strconv"] || strings.HasPrefix(f.Parse, "strconv.")
+	}
+
+	for _, pkg := range []string{"strconv", "time"} {
+		if uses[pkg] {
+			m.Imports = append(m.Imports, pkg)
+		}
+	}
+	return m
+}
+
+var modelTmpl = template.Must(template.New("model").Parse(header + `
+package {{.Pkg}}
+
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/lib"
+{{- if or (not .Keys) .Parsed}}
+	"github.com/zicare/go-rpg/msg"
+{{- end}}
+	"github.com/zicare/go-rpg/rest"
+{{- if .Keys}}
+	"github.com/zicare/go-rpg/validation"
+{{- end}}
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//{{.Type}} exported
+type {{.Type}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.GoType}} ` + "`{{.Tag}}`" + `
+{{- end}}
+}
+
+//New exported
+func (*{{.Type}}) New() db.Model {
+	return new({{.Type}})
+}
+
+//Table exported
+func (*{{.Type}}) Table() string {
+	return "{{.Table}}"
+}
+
+//View exported
+func (*{{.Type}}) View() string {
+	return "{{.View}}"
+}
+
+//Val exported
+func ({{.Recv}} *{{.Type}}) Val() interface{} {
+	return *{{.Recv}}
+}
+
+//Xfrm exported
+//Makes changes to {{.Recv}} before sending the output on GET/HEAD requests
+func ({{.Recv}} *{{.Type}}) Xfrm(c *gin.Context) db.Model {
+	return {{.Recv}}
+}
+
+//Bind exported
+//The request body is already bound, pIDs are the ids of the route
+func ({{.Recv}} *{{.Type}}) Bind(c *gin.Context, pIDs []lib.Pair) error {
+{{- if .Keys}}
+
+	for _, p := range pIDs {
+		switch p.A {
+{{- range .Keys}}
+		case "{{.Col}}":
+{{- if .Parse}}
+			v, err := {{.Parse}}
+			if err != nil {
+				e := new(db.ParamError)
+				e.Copy(msg.Get("23").SetArgs(p.B, "{{slice .GoType 1}}")) //Value is a %s, required type is %s
+				return e
+			}
+			{{$.Recv}}.{{.Name}} = &v
+{{- else if eq .GoType "[]byte"}}
+			{{$.Recv}}.{{.Name}} = []byte(p.B.(string))
+{{- else}}
+			v := p.B.(string)
+			{{$.Recv}}.{{.Name}} = &v
+{{- end}}
+{{- end}}
+		}
+	}
+	return validation.Struct({{.Recv}})
+{{- else}}
+	//Read only model
+	return msg.Get("16").M2E()
+{{- end}}
+}
+
+//Validation exported
+//Makes final struct level validation before trying to persist to db
+func (*{{.Type}}) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+//Delete exported
+func (*{{.Type}}) Delete(c *gin.Context, pIDs []lib.Pair) error {
+{{- if .Keys}}
+	return db.ErrDefaultDelete
+{{- else}}
+	//Read only model
+	return msg.Get("16").M2E()
+{{- end}}
+}
+
+//Scope exported
+func (*{{.Type}}) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+//{{.Type}}Controller exported
+type {{.Type}}Controller struct {
+	rest.Controller
+}
+
+//Index exported
+func (ctrl {{.Type}}Controller) Index(c *gin.Context) {
+	ctrl.Controller.Index(c, new({{.Type}}))
+}
+
+//IndexHead exported
+func (ctrl {{.Type}}Controller) IndexHead(c *gin.Context) {
+	ctrl.Controller.IndexHead(c, new({{.Type}}))
+}
+{{- if .Keys}}
+
+//Get exported
+func (ctrl {{.Type}}Controller) Get(c *gin.Context) {
+	ctrl.Controller.Get(c, new({{.Type}}))
+}
+
+//Post exported
+func (ctrl {{.Type}}Controller) Post(c *gin.Context) {
+	ctrl.Controller.Post(c, new({{.Type}}))
+}
+
+//Put exported
+func (ctrl {{.Type}}Controller) Put(c *gin.Context) {
+	ctrl.Controller.Put(c, new({{.Type}}))
+}
+
+//Patch exported
+func (ctrl {{.Type}}Controller) Patch(c *gin.Context) {
+	ctrl.Controller.Patch(c, new({{.Type}}))
+}
+
+//Delete exported
+func (ctrl {{.Type}}Controller) Delete(c *gin.Context) {
+	ctrl.Controller.Delete(c, new({{.Type}}))
+}
+{{- end}}
+`))
+
+var routesTmpl = template.Must(template.New("routes").Parse(header + `
+package {{.Pkg}}
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/db"
+)
+
+//Models returns a new instance of every model, i.e. for db.Init
+func Models() []db.Model {
+	return []db.Model{
+{{- range .Models}}
+		new({{.Type}}),
+{{- end}}
+	}
+}
+
+//Routes registers the routes of every model under r,
+//handlers run before the controller ones, i.e. mw.Tx
+func Routes(r gin.IRouter, handlers ...gin.HandlerFunc) {
+
+	var g gin.IRouter
+{{- range .Models}}
+{{- $ctrl := printf "%sController{}" .Type}}
+
+	g = r.Group("{{.Path}}", handlers...)
+	g.GET("", {{$ctrl}}.Index)
+	g.HEAD("", {{$ctrl}}.IndexHead)
+{{- if .Keys}}
+	g.POST("", {{$ctrl}}.Post)
+	g.GET("/:id", {{$ctrl}}.Get)
+	g.PUT("/:id", {{$ctrl}}.Put)
+	g.PATCH("/:id", {{$ctrl}}.Patch)
+	g.DELETE("/:id", {{$ctrl}}.Delete)
+{{- end}}
+{{- end}}
+}
+`))
+
+//render returns the template executed with data, gofmt'ed
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", tmpl.Name(), err)
+	}
+	return src, nil
+}
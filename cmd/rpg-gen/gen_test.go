@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNames(t *testing.T) {
+
+	for _, tc := range []struct {
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{camel, "order_items", "OrderItems"},
+		{camel, "user_id", "UserID"},
+		{camel, "api-key.json", "APIKeyJSON"},
+		{camel, "2fa_codes", "X2faCodes"},
+		{camel, "", "X"},
+		{singular, "categories", "category"},
+		{singular, "addresses", "address"},
+		{singular, "boxes", "box"},
+		{singular, "status", "status"},
+		{singular, "class", "class"},
+		{singular, "orders", "order"},
+		{singular, "staff", "staff"},
+	} {
+		if got := tc.fn(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestGoType(t *testing.T) {
+
+	for _, tc := range []struct {
+		sqlType string
+		want    string
+	}{
+		{"integer", "*int64"},
+		{"bigserial", "*int64"},
+		{"INT4", "*int64"},
+		{"numeric(10,2)", "*float64"},
+		{"double precision", "*float64"},
+		{"boolean", "*bool"},
+		{"timestamp with time zone", "*time.Time"},
+		{"date", "*time.Time"},
+		{"bytea", "[]byte"},
+		{"integer[]", "*string"},
+		{"_int4", "*string"},
+		{"character varying(20)", "*string"},
+		{"jsonb", "*string"},
+		{"uuid", "*string"},
+	} {
+		if got := goType(tc.sqlType); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.sqlType, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+/*
+ * rpg-gen generates the db.Model implementations, controllers and
+ * routes of the tables of a postgres schema, read from the db set in
+ * the configuration or from a DDL file, i.e. a pg_dump --schema-only
+ * output:
+ *
+ *	rpg-gen -env development -dir . -out models
+ *	rpg-gen -ddl schema.sql -out models -tables persons,orders
+ *
+ * Every table gets a file named after it plus _gen.go with its model and
+ * controller, routes_gen.go holds Models and Routes. A view named -view-prefix plus the
+ * table name, holding all the table columns, becomes the View() of the
+ * table model, its other columns are tagged view:"1". Other views and
+ * tables without a primary key get read only models.
+ *
+ * Generated files start with a "Code generated" line and are rewritten
+ * on every run, only if their content changed. Delete that line to keep
+ * a file, i.e. after implementing Xfrm or Scope, rpg-gen leaves it alone.
+ * Unless -tables is given, generated files of tables no longer found
+ * are removed, so the output is the same as on a clean directory.
+ */
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zicare/go-rpg/config"
+	"github.com/zicare/go-rpg/db"
+)
+
+func main() {
+
+	var (
+		env    = flag.String("env", "development", "configuration `name`, the db is the one set in config/<name>.json")
+		dir    = flag.String("dir", ".", "`directory` holding the config directory")
+		ddl    = flag.String("ddl", "", "DDL `file` to read the tables from instead of the db")
+		schema = flag.String("schema", "public", "db `schema`")
+		only   = flag.String("tables", "", "comma separated `tables` to generate, all if empty")
+		prefix = flag.String("view-prefix", "view_", "`prefix` of the views of the tables")
+		out    = flag.String("out", "models", "output `directory`")
+		pkg    = flag.String("pkg", "", "package `name`, the output directory name if empty")
+		tables []*table
+		err    error
+	)
+
+	log.SetFlags(0)
+	log.SetPrefix("rpg-gen: ")
+	flag.Parse()
+
+	if *ddl != "" {
+		src, err := ioutil.ReadFile(*ddl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tables = parseDDL(string(src), *schema)
+	} else {
+		if err := config.Init(*env, *dir); err != nil {
+			log.Fatal(err)
+		} else if d := config.Config().GetString("db.driver"); d != "" && d != "postgres" {
+			log.Fatalf("unsupported db driver %s, use -ddl", d)
+		} else if err := db.Init(); err != nil {
+			log.Fatal(err)
+		}
+		if tables, err = inspect(db.Db(), *schema); err != nil {
+			log.Fatal(err)
+		}
+		tables = attach(tables, *prefix)
+	}
+
+	if tables = filter(tables, *only); len(tables) == 0 {
+		log.Fatal("no tables found")
+	}
+
+	if *pkg == "" {
+		abs, err := filepath.Abs(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*pkg = strings.Replace(filepath.Base(abs), "-", "_", -1)
+	}
+	if err = generate(tables, *out, *pkg, *only == ""); err != nil {
+		log.Fatal(err)
+	}
+}
+
+//generate writes the files of tables to the out directory, in package
+//pkg, removing the generated files of other tables if all is set
+func generate(tables []*table, out string, pkg string, all bool) error {
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+
+	var (
+		models  []model
+		types   = make(map[string]string)
+		written = make(map[string]bool)
+	)
+
+	for _, t := range tables {
+		m := newModel(t, pkg)
+		if other, ok := types[m.Type]; ok {
+			return fmt.Errorf("tables %s and %s are both %s", other, t.Name, m.Type)
+		}
+		types[m.Type] = t.Name
+		models = append(models, m)
+
+		src, err := render(modelTmpl, m)
+		if err != nil {
+			return err
+		}
+		name := strings.Replace(t.Name, ".", "_", -1) + "_gen.go"
+		if name == "routes_gen.go" {
+			name = "routes_table_gen.go"
+		}
+		if err := write(filepath.Join(out, name), src); err != nil {
+			return err
+		}
+		written[name] = true
+	}
+
+	src, err := render(routesTmpl, struct {
+		Pkg    string
+		Models []model
+	}{pkg, models})
+	if err != nil {
+		return err
+	} else if err := write(filepath.Join(out, "routes_gen.go"), src); err != nil {
+		return err
+	}
+	written["routes_gen.go"] = true
+
+	if all {
+		return prune(out, written)
+	}
+	return nil
+}
+
+//prune removes the generated files in dir not written by this run,
+//those of dropped tables or named as older versions did
+func prune(dir string, written map[string]bool) error {
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range files {
+		if written[filepath.Base(path)] {
+			continue
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		} else if !bytes.HasPrefix(src, []byte(header)) {
+			continue
+		} else if err := os.Remove(path); err != nil {
+			return err
+		}
+		fmt.Println("removed  ", path)
+	}
+	return nil
+}
+
+//write writes src to the file at path unless it holds src already
+//or it isn't a generated file, reporting what was done
+func write(path string, src []byte) error {
+
+	old, err := ioutil.ReadFile(path)
+	switch {
+	case err != nil && !os.IsNotExist(err):
+		return err
+	case err == nil && !bytes.HasPrefix(old, []byte(header)):
+		fmt.Println("kept     ", path)
+		return nil
+	case err == nil && bytes.Equal(old, src):
+		fmt.Println("unchanged", path)
+		return nil
+	}
+
+	if err := ioutil.WriteFile(path, src, 0644); err != nil {
+		return err
+	}
+	fmt.Println("wrote    ", path)
+	return nil
+}
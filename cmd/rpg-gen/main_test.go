@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+//files returns the names and contents of the files in dir
+func files(t *testing.T, dir string) map[string]string {
+
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]string)
+	for _, p := range paths {
+		src, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[filepath.Base(p)] = string(src)
+	}
+	return m
+}
+
+//names returns the keys of m, sorted
+func names(m map[string]string) (s []string) {
+
+	for k := range m {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return
+}
+
+func TestGenerate(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "rpg-gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		ddl = `CREATE TABLE items (id serial PRIMARY KEY, name text NOT NULL);
+			CREATE TABLE orders (id bigint PRIMARY KEY, placed date);`
+		kept = "package models\n\n//custom\n"
+	)
+
+	//a file the user took over, without the generated header
+	if err := ioutil.WriteFile(filepath.Join(dir, "orders_gen.go"), []byte(kept), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := generate(parseDDL(ddl, "public"), dir, "models", true); err != nil {
+		t.Fatal(err)
+	}
+	first := files(t, dir)
+	if got := names(first); len(got) != 3 || got[0] != "items_gen.go" || got[1] != "orders_gen.go" || got[2] != "routes_gen.go" {
+		t.Fatalf("generated %v", got)
+	} else if first["orders_gen.go"] != kept {
+		t.Error("overwrote a file without the header")
+	}
+
+	//run twice, nothing changes
+	if err := generate(parseDDL(ddl, "public"), dir, "models", true); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files(t, dir) {
+		if src != first[name] {
+			t.Errorf("%s changed on the second run", name)
+		}
+	}
+
+	//items dropped, its file is stale, unless only some tables are generated
+	ddl = `CREATE TABLE orders (id bigint PRIMARY KEY, placed date);
+		CREATE TABLE carts (id bigint PRIMARY KEY);`
+	if err := generate(parseDDL(ddl, "public")[1:], dir, "models", false); err != nil {
+		t.Fatal(err)
+	} else if _, ok := files(t, dir)["items_gen.go"]; !ok {
+		t.Error("pruned with some tables only")
+	}
+	if err := generate(parseDDL(ddl, "public"), dir, "models", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(files(t, dir)); len(got) != 3 || got[0] != "carts_gen.go" || got[1] != "orders_gen.go" || got[2] != "routes_gen.go" {
+		t.Errorf("after dropping items got %v", got)
+	}
+}
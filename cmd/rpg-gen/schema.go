@@ -0,0 +1,174 @@
+package main
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+//table is a table or view models are generated for
+type table struct {
+	Name    string
+	View    string
+	IsView  bool
+	Columns []column
+}
+
+//column is a column of a table, Type is the sql type name
+type column struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Default  bool
+	Primary  bool
+	Serial   bool
+	View     bool
+}
+
+//col returns the column named, nil if there's none
+func (t *table) col(name string) *column {
+
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+//inspect returns the tables and views of schema in a postgres db
+func inspect(conn *sql.DB, schema string) ([]*table, error) {
+
+	var (
+		tables = []*table{}
+		byName = make(map[string]*table)
+	)
+
+	rows, err := conn.Query(`SELECT c.table_name, t.table_type = 'VIEW', c.column_name,
+		CASE WHEN c.data_type = 'ARRAY' THEN c.udt_name ELSE c.data_type END,
+		c.is_nullable = 'YES', c.column_default IS NOT NULL OR c.is_identity = 'YES',
+		COALESCE(c.column_default LIKE 'nextval(%', false) OR c.is_identity = 'YES'
+		FROM information_schema.columns c
+		JOIN information_schema.tables t
+		ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = $1
+		ORDER BY c.table_name, c.ordinal_position`, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name   string
+			isView bool
+			c      column
+		)
+		if err := rows.Scan(&name, &isView, &c.Name, &c.Type, &c.Nullable, &c.Default, &c.Serial); err != nil {
+			return nil, err
+		}
+		t, ok := byName[name]
+		if !ok {
+			t = &table{Name: name, IsView: isView}
+			byName[name] = t
+			tables = append(tables, t)
+		}
+		t.Columns = append(t.Columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pks, err := conn.Query(`SELECT k.table_name, k.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage k
+		ON k.constraint_schema = tc.constraint_schema AND k.constraint_name = tc.constraint_name
+		WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = $1`, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer pks.Close()
+
+	for pks.Next() {
+		var name, col string
+		if err := pks.Scan(&name, &col); err != nil {
+			return nil, err
+		}
+		if t, ok := byName[name]; ok {
+			if c := t.col(col); c != nil {
+				c.Primary = true
+			}
+		}
+	}
+	return tables, pks.Err()
+}
+
+//attach sets the view of every table to the one named prefix plus
+//the table name, if any holds all the table columns. Columns only in
+//the view are added as view columns, views attached are dropped.
+func attach(tables []*table, prefix string) []*table {
+
+	var (
+		out    []*table
+		byName = make(map[string]*table)
+		used   = make(map[string]bool)
+	)
+
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+
+	for _, t := range tables {
+		v, ok := byName[prefix+t.Name]
+		if t.IsView || !ok || !v.IsView || prefix == "" {
+			continue
+		}
+		all := true
+		for _, c := range t.Columns {
+			all = all && v.col(c.Name) != nil
+		}
+		if !all {
+			continue
+		}
+		t.View, used[v.Name] = v.Name, true
+		for _, c := range v.Columns {
+			if t.col(c.Name) == nil {
+				c.View, c.Default, c.Serial, c.Primary = true, false, false, false
+				t.Columns = append(t.Columns, c)
+			}
+		}
+	}
+
+	for _, t := range tables {
+		if !used[t.Name] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+//filter returns the tables named in only, a comma separated list,
+//all of them if empty, sorted by name
+func filter(tables []*table, only string) []*table {
+
+	var (
+		out   []*table
+		names = make(map[string]bool)
+	)
+
+	for _, name := range strings.Split(only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = true
+		}
+	}
+
+	for _, t := range tables {
+		if len(names) == 0 || names[t.Name] {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
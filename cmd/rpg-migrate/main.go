@@ -0,0 +1,66 @@
+/*
+ * rpg-migrate applies the SQL migrations in a directory, and the bundled
+ * ones asked for, to the db set in the configuration:
+ *
+ *	rpg-migrate -env development -dir . -path migrations -bundled acl,cors up
+ *	rpg-migrate -path migrations down 2
+ *	rpg-migrate -path migrations status
+ *
+ * See migrate.Run for the commands. Applications with Go migrations
+ * build their own command around migrate.Run.
+ */
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/zicare/go-rpg/config"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/db/migrate"
+)
+
+func main() {
+
+	var (
+		env     = flag.String("env", "development", "configuration `name`, the db is the one set in config/<name>.json")
+		dir     = flag.String("dir", ".", "`directory` holding the config directory")
+		path    = flag.String("path", "", "`directory` holding the SQL migrations")
+		bundled = flag.String("bundled", "", "comma separated bundled `migrations` to include, acl or cors")
+		ms      []migrate.Migration
+	)
+
+	log.SetFlags(0)
+	log.SetPrefix("rpg-migrate: ")
+	flag.Parse()
+
+	if *path != "" {
+		loaded, err := migrate.Load(os.DirFS(*path))
+		if err != nil {
+			log.Fatal(err)
+		}
+		ms = append(ms, loaded...)
+	}
+
+	for _, b := range strings.Split(*bundled, ",") {
+		switch strings.TrimSpace(b) {
+		case "":
+		case "acl":
+			ms = append(ms, migrate.ACL()...)
+		case "cors":
+			ms = append(ms, migrate.CORS()...)
+		default:
+			log.Fatalf("unknown bundled migrations %s", b)
+		}
+	}
+
+	if err := config.Init(*env, *dir); err != nil {
+		log.Fatal(err)
+	} else if err := db.Init(); err != nil {
+		log.Fatal(err)
+	} else if err := migrate.Run(db.Db(), ms, flag.Args(), os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,9 +1,6 @@
 package cors
 
 import (
-	"reflect"
-
-	"github.com/huandu/go-sqlbuilder"
 	"github.com/zicare/go-rpg/db"
 	"github.com/zicare/go-rpg/msg"
 )
@@ -15,24 +12,22 @@ func Init(m db.Model) (err error) {
 
 	var (
 		f [2]string
-		t = reflect.Indirect(reflect.ValueOf(m))
 
 		key    string
 		origin string
 
-		sb = sqlbuilder.PostgreSQL.NewSelectBuilder()
+		sb = db.Flavor().NewSelectBuilder()
 	)
 
-	for i := 0; i < t.NumField(); i++ {
-		if tag, ok := t.Type().Field(i).Tag.Lookup("cors"); ok {
-			if col, ok := t.Type().Field(i).Tag.Lookup("db"); ok {
-				switch tag {
-				case "key":
-					f[0] = col
-				case "origin":
-					f[1] = col
-				}
-			}
+	if err = db.Register(m); err != nil {
+		return
+	}
+
+	fields, _ := db.Fields(m)
+
+	for i, tag := range []string{"key", "origin"} {
+		if cols := fields.Tagged("cors", tag); len(cols) == 1 {
+			f[i] = cols[0]
 		}
 	}
 
@@ -50,9 +45,16 @@ func Init(m db.Model) (err error) {
 	//	sb.GreaterThan(f[4], now),
 	//)
 
+	ctx, cancel := db.Context(nil, m)
+	defer cancel()
+
 	sql, args := sb.Build()
 	//log.Println(sql, args)
-	rows, err := db.Db().Query(sql, args...)
+	rows, err := db.Db().QueryContext(ctx, sql, args...)
+	if err != nil {
+		//Server error: %s
+		return msg.Get("25").SetArgs(err).M2E()
+	}
 	defer rows.Close()
 
 	//scan rows
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/msg"
+	"github.com/zicare/go-rpg/slice"
+)
+
+//aggregates FetchAll accepts, i.e. ?agg=count(*),sum(amount)
+var aggregates = regexp.MustCompile(`^(count|sum|avg|min|max)\(([^()]+)\)$`)
+
+//Aggregate exported
+//Returns the rows of m grouped by the columns in ?group= along with
+//the aggregates in ?agg=, i.e. ?group=status&agg=count(*),sum(amount).
+//Aggregates are count, sum, avg, min and max, named like count,
+//sum_amount or avg_price in the result. The scope, filters and limit
+//of FetchAll apply. Unknown columns or aggregates return ParamError.
+func Aggregate(c *gin.Context, m Model) ([]map[string]interface{}, error) {
+
+	var (
+		results   = []map[string]interface{}{}
+		opt       = params(c, m)
+		fields, _ = Fields(m)
+		table     = m.View()
+		sb        = Flavor().NewSelectBuilder()
+		cols      []string
+		group     []string
+	)
+
+	sb.From(table)
+
+	//group by
+	for _, k := range strings.Split(c.Query("group"), ",") {
+		if k == "" {
+			continue
+		} else if !slice.Contains(fields.Ordered, k) {
+			e := new(ParamError)
+			e.Copy(msg.Get("47").SetArgs(k)) //Unknown column %s
+			return results, e
+		}
+		group = append(group, k)
+		cols = append(cols, fmt.Sprintf("%s.%s", table, k))
+	}
+
+	//aggregates
+	agg := c.DefaultQuery("agg", "count(*)")
+	for _, a := range strings.Split(agg, ",") {
+		expr, alias, err := aggregate(m, fields.Ordered, table, strings.TrimSpace(a))
+		if err != nil {
+			return results, err
+		}
+		cols = append(cols, sb.As(expr, alias))
+	}
+
+	//set where
+	narrow(c, m, sb, opt, table)
+
+	sb.Select(cols...)
+	if len(group) > 0 {
+		sb.GroupBy(prefix(group, table)...)
+		sb.OrderBy(prefix(group, table)...)
+	}
+	sb.Limit(opt.Limit)
+	sb.Offset(opt.Offset)
+
+	ctx, cancel := Context(c, m)
+	defer cancel()
+
+	q, args := sb.Build()
+	//fmt.Println(q, args)
+	rows, err := Conn(c).QueryContext(ctx, q, args...)
+	if err != nil {
+		//Server error: %s
+		return results, fail(c, err)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		//Server error: %s
+		return results, fail(c, err)
+	}
+
+	for rows.Next() {
+		var (
+			vals = make([]interface{}, len(names))
+			addr = make([]interface{}, len(names))
+			row  = make(map[string]interface{})
+		)
+		for i := range vals {
+			addr[i] = &vals[i]
+		}
+		if err := rows.Scan(addr...); err != nil {
+			//Server error: %s
+			return results, fail(c, err)
+		}
+		for i, k := range names {
+			row[k] = scalar(vals[i])
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		//Server error: %s
+		return results, fail(c, err)
+	}
+	return results, nil
+}
+
+//aggregate returns the sql expression and the alias for a, i.e. sum(amount).
+//sum and avg are only allowed on numeric columns.
+func aggregate(m Model, cols []string, table string, a string) (expr string, alias string, err error) {
+
+	var (
+		j = aggregates.FindStringSubmatch(strings.ToLower(a))
+		e = new(ParamError)
+	)
+
+	e.Copy(msg.Get("46").SetArgs(a)) //Invalid aggregate %s
+
+	if j == nil {
+		return "", "", e
+	} else if fn, k := j[1], j[2]; k == "*" && fn == "count" {
+		return "COUNT(*)", "count", nil
+	} else if !slice.Contains(cols, k) {
+		return "", "", e
+	} else if (fn == "sum" || fn == "avg") && !numeric(m, k) {
+		return "", "", e
+	} else {
+		return fmt.Sprintf("%s(%s.%s)", strings.ToUpper(fn), table, k), fn + "_" + k, nil
+	}
+}
+
+//numeric tells if the column k of m holds numbers
+func numeric(m Model, k string) bool {
+
+	_, val := Fields(m)
+	t := reflect.TypeOf(val[k])
+	if t == nil {
+		return false
+	} else if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+//scalar returns v as sent by the driver, raw bytes such as postgres
+//numeric values are returned as numbers if they're so, strings otherwise
+func scalar(v interface{}) interface{} {
+
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	} else if _, err := strconv.ParseFloat(string(b), 64); err == nil {
+		return json.Number(b)
+	}
+	return string(b)
+}
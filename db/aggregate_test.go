@@ -0,0 +1,109 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/lib"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//voided sales are out of scope
+type sale struct {
+	ID     *int64   `db:"id"     json:"id"     primary:"1" serial:"1"`
+	Region *string  `db:"region" json:"region"`
+	Amount *float64 `db:"amount" json:"amount"`
+	Voided *bool    `db:"voided" json:"voided"`
+}
+
+func (*sale) New() Model {
+	return new(sale)
+}
+
+func (*sale) Table() string {
+	return "sales"
+}
+
+func (*sale) View() string {
+	return "sales"
+}
+
+func (s *sale) Val() interface{} {
+	return *s
+}
+
+func (s *sale) Xfrm(c *gin.Context) Model {
+	return s
+}
+
+func (s *sale) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*sale) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*sale) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return ErrDefaultDelete
+}
+
+func (*sale) Scope(b sqlbuilder.Builder, c *gin.Context) {
+	if sb, ok := b.(*sqlbuilder.SelectBuilder); ok {
+		sb.Where("sales.voided = 0")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+
+	schema(t,
+		"DROP TABLE IF EXISTS sales",
+		"CREATE TABLE sales (id INTEGER PRIMARY KEY, region VARCHAR(10), amount REAL, voided BOOLEAN NOT NULL DEFAULT 0)",
+		`INSERT INTO sales (region, amount, voided) VALUES
+			('north', 10, 0), ('north', 20, 0), ('north', 1000, 1),
+			('south', 5, 0), ('west', 7, 0)`,
+	)
+
+	for _, tc := range []struct {
+		target string
+		want   string
+	}{
+		//scoped, voided sales are left out
+		{"/sales?group=region&agg=count(*),sum(amount)", "[map[count:2 region:north sum_amount:30] map[count:1 region:south sum_amount:5] map[count:1 region:west sum_amount:7]]"},
+		{"/sales?agg=max(amount)", "[map[max_amount:20]]"},
+		//filtered before aggregating
+		{"/sales?group=region&agg=avg(amount)&eq=region|north", "[map[avg_amount:15 region:north]]"},
+		{"/sales?agg=count(*)&gt=amount|6", "[map[count:3]]"},
+	} {
+		rows, err := Aggregate(request("GET", tc.target), new(sale))
+		if err != nil {
+			t.Errorf("%s: %v", tc.target, err)
+		} else if got := fmt.Sprint(rows); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.target, got, tc.want)
+		}
+	}
+
+	for _, target := range []string{
+		//unknown columns
+		"/sales?group=nope",
+		"/sales?agg=sum(nope)",
+		//not a whitelisted function or column
+		"/sales?agg=median(amount)",
+		"/sales?agg=count(amount)%3BDROP%20TABLE%20sales",
+		"/sales?agg=sum(sales.amount)",
+		//not numeric
+		"/sales?agg=sum(region)",
+		"/sales?agg=avg(region)",
+	} {
+		if _, err := Aggregate(request("GET", target), new(sale)); err == nil {
+			t.Errorf("%s: got no error, want ParamError", target)
+		} else if _, ok := err.(*ParamError); !ok {
+			t.Errorf("%s: got %T, want ParamError", target, err)
+		}
+	}
+
+	//min and max take any column
+	if _, err := Aggregate(request("GET", "/sales?agg=min(region)"), new(sale)); err != nil {
+		t.Errorf("min(region): %v", err)
+	}
+}
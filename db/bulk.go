@@ -0,0 +1,207 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/msg"
+)
+
+//BulkResult exported
+//Outcome of one item of a bulk request. Model holds the item,
+//as read back from the db when Err is nil.
+type BulkResult struct {
+	Model Model
+	Err   error
+}
+
+//InsertMany exported
+//Binds every item of the JSON array in the request body the way Insert
+//does, and inserts them within one transaction. Items are bound within
+//it too, after the ones before them are written, so validations such as
+//unique see the whole batch. Nothing is written unless every item
+//succeeds. The outcome of each item is returned either way.
+func InsertMany(c *gin.Context, m Model) ([]BulkResult, error) {
+
+	return bindMany(c, m, func(item Model) error {
+		return bind(c, item, []lib.Pair{})
+	}, func(r *BulkResult) error {
+		//rows created out of the read scope aren't failures
+		if err := create(c, r.Model); err != nil {
+			if _, ok := err.(*NotFoundError); !ok {
+				return err
+			}
+			r.Err = err
+		}
+		return nil
+	})
+}
+
+//UpdateMany exported
+//Like InsertMany but updating, every item must hold its primary key.
+//Versioned items must hold their version too unless If-Match is *.
+func UpdateMany(c *gin.Context, m Model) ([]BulkResult, error) {
+
+	var (
+		fields, _ = Fields(m)
+		ids       = make(map[Model][]lib.Pair)
+		matches   = make(map[Model][]string)
+		wildcard  = c.GetHeader("If-Match") == "*"
+	)
+
+	return bindMany(c, m, func(item Model) error {
+		id, ok := keys(item, fields.Primary)
+		if !ok {
+			e := new(ParamError)
+			e.Copy(msg.Get("26")) //Composite key missuse
+			return e
+		}
+		ids[item] = id
+		if tag := ETag(item); tag != "" && !wildcard {
+			matches[item] = []string{strings.Trim(tag, `"`)}
+		} else if fields.Version != "" && !wildcard {
+			e := new(PreconditionRequiredError)
+			e.Copy(msg.Get("45")) //If-Match header is required
+			return e
+		}
+		return bind(c, item, id)
+	}, func(r *BulkResult) error {
+		return update(c, r.Model, ids[r.Model], nil, matches[r.Model])
+	})
+}
+
+//DeleteMany exported
+//Deletes the rows whose ids, formatted as the id param, i.e. "1" or "1,2"
+//for composite keys, are listed in the JSON array in the request body.
+//Rows are deleted through Remove within one transaction.
+func DeleteMany(c *gin.Context, m Model) ([]BulkResult, error) {
+
+	var (
+		fields, _ = Fields(m)
+		items     []json.RawMessage
+		ids       = make(map[Model][]lib.Pair)
+		results   []BulkResult
+	)
+
+	if err := c.ShouldBindJSON(&items); err != nil {
+		e := new(ParamError)
+		e.Copy(msg.Get("35")) //A JSON array is required
+		return results, e
+	}
+
+	var failed bool
+	for _, raw := range items {
+		var (
+			s    string
+			item = m.New()
+			r    = BulkResult{Model: item}
+		)
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		if p := strings.Split(s, ","); len(p) != len(fields.Primary) {
+			e := new(ParamError)
+			e.Copy(msg.Get("26")) //Composite key missuse
+			r.Err, failed = e, true
+		} else {
+			for i, k := range fields.Primary {
+				ids[item] = append(ids[item], lib.Pair{A: k, B: p[i]})
+			}
+		}
+		results = append(results, r)
+	}
+	if failed {
+		e := new(ParamError)
+		e.Copy(msg.Get("19")) //There are validation errors
+		return results, e
+	}
+
+	return results, writeMany(c, results, func(r *BulkResult) error {
+		return Remove(c, r.Model, ids[r.Model])
+	})
+}
+
+//bindMany binds every item in the request body into a new model and
+//writes it, within one transaction. bind completes the binding as
+//Model.Bind is called with the item ids. Items failing to bind are
+//reported and the rest still bound, but nothing else is written.
+func bindMany(c *gin.Context, m Model, bind func(Model) error, write func(*BulkResult) error) ([]BulkResult, error) {
+
+	var (
+		items   []json.RawMessage
+		results []BulkResult
+	)
+
+	if err := c.ShouldBindJSON(&items); err != nil {
+		e := new(ParamError)
+		e.Copy(msg.Get("35")) //A JSON array is required
+		return results, e
+	}
+
+	err := WithTx(c, func() error {
+
+		var failed bool
+
+		//from scratch on every retry
+		results = make([]BulkResult, len(items))
+		for i, raw := range items {
+			r := &results[i]
+			r.Model = m.New()
+			if r.Err = json.Unmarshal(raw, r.Model); r.Err == nil {
+				rebind(c, raw)
+				r.Err = bind(r.Model)
+			}
+			if r.Err != nil {
+				failed = true
+			} else if failed {
+				continue
+			} else if err := write(r); err != nil {
+				r.Err = err
+				return err
+			}
+		}
+
+		if failed {
+			e := new(ParamError)
+			e.Copy(msg.Get("19")) //There are validation errors
+			return e
+		}
+		return nil
+	})
+	return results, err
+}
+
+//writeMany runs write for every result within one transaction,
+//stopping at the first failure
+func writeMany(c *gin.Context, results []BulkResult, write func(*BulkResult) error) error {
+
+	return WithTx(c, func() error {
+		for i := range results {
+			results[i].Err = nil
+		}
+		for i := range results {
+			if err := write(&results[i]); err != nil {
+				results[i].Err = err
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//keys returns the values of m for cols, false if any of them is missing
+func keys(m Model, cols []string) (id []lib.Pair, ok bool) {
+
+	_, val := Fields(m)
+	for _, k := range cols {
+		if isNull(val[k]) {
+			return nil, false
+		}
+		id = append(id, lib.Pair{A: k, B: fmt.Sprintf("%v", reflect.Indirect(reflect.ValueOf(val[k])))})
+	}
+	return id, true
+}
@@ -0,0 +1,91 @@
+package db_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/validation"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//tag names are unique through the unique validation alone
+type tag struct {
+	ID   *int64  `db:"id"   json:"id"   primary:"1" serial:"1"`
+	Name *string `db:"name" json:"name" validate:"unique=name"`
+}
+
+func (*tag) New() db.Model {
+	return new(tag)
+}
+
+func (*tag) Table() string {
+	return "tags"
+}
+
+func (*tag) View() string {
+	return "tags"
+}
+
+func (t *tag) Val() interface{} {
+	return *t
+}
+
+func (t *tag) Xfrm(c *gin.Context) db.Model {
+	return t
+}
+
+func (t *tag) Bind(c *gin.Context, pIDs []lib.Pair) error {
+
+	if err := c.ShouldBind(t); err != nil {
+		return err
+	}
+	return validation.Struct(t)
+}
+
+func (*tag) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*tag) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return db.ErrDefaultDelete
+}
+
+func (*tag) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+func TestInsertManyDuplicates(t *testing.T) {
+
+	//gin's own engine may not be v8, the package one is
+	validation.Init()
+
+	for _, q := range []string{
+		"DROP TABLE IF EXISTS tags",
+		"CREATE TABLE tags (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(100))",
+	} {
+		if _, err := db.Db().Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/tags", strings.NewReader(`[{"name": "a"}, {"name": "b"}, {"name": "a"}]`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	results, err := db.InsertMany(c, new(tag))
+	if _, ok := err.(*db.ParamError); !ok {
+		t.Fatalf("got %T, want *db.ParamError", err)
+	} else if len(results) != 3 || results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("got %v, want the first two items to pass", results)
+	} else if _, ok := results[2].Err.(validator.ValidationErrors); !ok {
+		t.Errorf("got %T for the duplicate, want validator.ValidationErrors", results[2].Err)
+	}
+
+	var n int
+	if err := db.Db().QueryRow("SELECT COUNT(*) FROM tags").Scan(&n); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("got %d rows, want the batch rolled back", n)
+	}
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"encoding/json"
+	"hash/crc64"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var table64 = crc64.MakeTable(crc64.ECMA)
+
+//Digest exported
+//Returns the strong entity tag for the JSON representation of v
+func Digest(v interface{}) string {
+
+	b, _ := json.Marshal(v)
+	return `"` + strconv.FormatUint(crc64.Checksum(b, table64), 16) + `"`
+}
+
+//LastModified exported
+//Returns the value of the column tagged updated:"1",
+//the zero time if m has none or it's not set
+func LastModified(m Model) time.Time {
+
+	fields, val := Fields(m)
+	if fields.Updated == "" {
+		return time.Time{}
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(val[fields.Updated]))
+	if !v.IsValid() {
+		return time.Time{}
+	} else if t, ok := v.Interface().(time.Time); ok {
+		return t
+	}
+	return time.Time{}
+}
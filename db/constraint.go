@@ -0,0 +1,124 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/msg"
+)
+
+//ConstraintError exported
+//A write violating a db constraint. Messages name the json fields
+//involved, shaped as the validation package messages are.
+type ConstraintError struct {
+	Violation Violation
+	Messages  msg.MessageList
+}
+
+//Error exported
+func (e *ConstraintError) Error() string {
+	return e.Messages.Error()
+}
+
+//Conflict exported
+//Tells if the row clashes with others, unique and foreign key
+//violations, rather than holding invalid values
+func (e *ConstraintError) Conflict() bool {
+	return e.Violation.Kind == "unique" || e.Violation.Kind == "fk"
+}
+
+//Key (email)=(john@example.com) already exists.
+var detailKey = regexp.MustCompile(`Key \(([^)]+)\)=`)
+
+//failWrite maps constraint violations to ConstraintError,
+//other errors are handled by fail
+func failWrite(c *gin.Context, m Model, err error) error {
+
+	v, ok := dialect.Violation(err)
+	if !ok {
+		return fail(c, err)
+	}
+
+	var (
+		e           = &ConstraintError{Violation: v}
+		fields, val = Fields(m)
+	)
+
+	cols := columns(v, fields.Ordered)
+	if len(cols) == 0 && v.Kind == "fk" {
+		cols = references(fields, val)
+	}
+
+	for _, k := range cols {
+		var (
+			field = k
+			value = fmt.Sprintf("%v", reflect.Indirect(reflect.ValueOf(val[k])))
+			em    msg.Message
+		)
+		if j, ok := fields.JSON[k]; ok {
+			field = j
+		}
+		switch v.Kind {
+		case "unique":
+			em = msg.Get("39").SetArgs(value) //Value %s already exists
+		case "notnull":
+			em = msg.Get("40") //Value is required
+		case "check":
+			em = msg.Get("41").SetArgs(v.Constraint) //Value didn't pass %s check
+		case "fk":
+			em = msg.Get("42").SetArgs(value) //Value %s doesn't reference an existing row
+		}
+		e.Messages = append(e.Messages, em.SetField(field))
+	}
+
+	if len(e.Messages) == 0 {
+		//Constraint %s violated
+		e.Messages = append(e.Messages, msg.Get("43").SetArgs(v.Constraint))
+	}
+	return e
+}
+
+//columns returns the columns involved in the violation,
+//as reported or else guessed from the detail and the constraint name
+func columns(v Violation, ordered []string) (cols []string) {
+
+	if v.Column != "" {
+		return []string{v.Column}
+	} else if k := detailKey.FindStringSubmatch(v.Detail); k != nil {
+		for _, c := range strings.Split(k[1], ",") {
+			cols = append(cols, strings.TrimSpace(c))
+		}
+		return
+	}
+
+	//i.e. users_email_key, the longest column found wins
+	var col string
+	for _, c := range ordered {
+		if strings.Contains(v.Constraint, c) && len(c) > len(col) {
+			col = c
+		}
+	}
+	if col != "" {
+		cols = append(cols, col)
+	}
+	return
+}
+
+//references returns the column of m tagged fk:"table.column" holding
+//a value, engines not telling the column violating a foreign key, none
+//if there are several
+func references(fields Meta, val map[string]interface{}) (cols []string) {
+
+	for _, k := range fields.Ordered {
+		if _, ok := fields.Tags["fk"][fields.Field[k]]; ok && !isNull(val[k]) {
+			cols = append(cols, k)
+		}
+	}
+	if len(cols) > 1 {
+		return nil
+	}
+	return
+}
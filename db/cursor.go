@@ -0,0 +1,120 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/msg"
+	"github.com/zicare/go-rpg/slice"
+)
+
+//keyset returns the cursor columns, the order columns followed by
+//the primary key columns not already ordered by, and their direction.
+//It also completes order so rows are sorted by the whole keyset.
+func keyset(order *[]string, primary []string) (cols []string, desc []bool) {
+
+	for _, o := range *order {
+		j := strings.Split(o, " ")
+		cols = append(cols, j[0])
+		desc = append(desc, len(j) > 1 && j[1] == "DESC")
+	}
+	for _, k := range primary {
+		if !slice.Contains(cols, k) {
+			cols = append(cols, k)
+			desc = append(desc, false)
+			*order = append(*order, k+" ASC")
+		}
+	}
+	return
+}
+
+//orderBy returns the order of the keyset. Engines sort nulls apart
+//differently, so they're placed explicitly after any value, before
+//them if descending, as seek takes them. Primary columns aren't null.
+func orderBy(table string, cols []string, desc []bool, primary []string) (order []string) {
+
+	for i, k := range cols {
+		dir := " ASC"
+		if desc[i] {
+			dir = " DESC"
+		}
+		k = fmt.Sprintf("%s.%s", table, k)
+		if !slice.Contains(primary, cols[i]) {
+			order = append(order, k+" IS NULL"+dir)
+		}
+		order = append(order, k+dir)
+	}
+	return
+}
+
+//seek returns the where expression selecting rows placed after vals
+//in the keyset order, i.e. (a > 1) OR (a = 1 AND b > 2). Nulls are
+//greater than any value, as in orderBy.
+func seek(cb *sqlbuilder.Cond, table string, cols []string, desc []bool, vals []interface{}) string {
+
+	var or []string
+
+	for i := range cols {
+		var and []string
+		for j := 0; j < i; j++ {
+			k := fmt.Sprintf("%s.%s", table, cols[j])
+			if vals[j] == nil {
+				and = append(and, cb.IsNull(k))
+			} else {
+				and = append(and, cb.Equal(k, vals[j]))
+			}
+		}
+		k := fmt.Sprintf("%s.%s", table, cols[i])
+		switch {
+		case vals[i] == nil && desc[i]:
+			and = append(and, cb.IsNotNull(k))
+		case vals[i] == nil:
+			//nothing is greater than null
+			continue
+		case desc[i]:
+			and = append(and, cb.LessThan(k, vals[i]))
+		default:
+			and = append(and, cb.Or(cb.GreaterThan(k, vals[i]), cb.IsNull(k)))
+		}
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	if len(or) == 0 {
+		return "1 = 0"
+	}
+	return cb.Or(or...)
+}
+
+//encodeCursor returns the opaque cursor for the keyset values
+func encodeCursor(vals []interface{}) string {
+
+	b, _ := json.Marshal(vals)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//decodeCursor returns the n keyset values held by cursor
+func decodeCursor(cursor string, n int) ([]interface{}, error) {
+
+	var (
+		vals []interface{}
+		e    = new(ParamError)
+	)
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		e.Copy(msg.Get("30")) //Invalid cursor
+		return nil, e
+	}
+
+	//numbers are kept as strings so big keys don't lose precision
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&vals); err != nil || len(vals) != n {
+		e.Copy(msg.Get("30")) //Invalid cursor
+		return nil, e
+	}
+	return vals, nil
+}
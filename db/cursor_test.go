@@ -0,0 +1,41 @@
+package db
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCursorNulls(t *testing.T) {
+
+	items(t, "b", "a", "c", "a")
+	schema(t, "INSERT INTO items (name) VALUES (NULL), (NULL), (NULL)")
+
+	for _, order := range []string{"name", "name|desc"} {
+		var (
+			seen  = make(map[int64]bool)
+			after = ""
+		)
+		for page := 0; page < 10; page++ {
+			c := request("GET", "/items?limit=2&xcols=name&order="+order+"&after="+url.QueryEscape(after))
+			meta, rows, err := FetchAll(c, new(item))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range rows {
+				if r.(item).Name != nil {
+					t.Errorf("%s: name was excluded but is set", order)
+				} else if id := *r.(item).ID; seen[id] {
+					t.Errorf("%s: row %d seen twice", order, id)
+				} else {
+					seen[id] = true
+				}
+			}
+			if after = meta.Next; after == "" {
+				break
+			}
+		}
+		if len(seen) != 7 {
+			t.Errorf("%s: got %d rows across pages, want 7", order, len(seen))
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/zicare/go-rpg/config"
 	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/slice"
 	"gopkg.in/go-playground/validator.v8"
 )
 
@@ -25,19 +26,31 @@ type SelectOpt struct {
 	Limit    int
 	Column   []string
 	Filter   map[string][]lib.Pair
+	Group    []FilterGroup
 	Null     []string
 	NotNull  []string
 	Order    []string
+	Cursor   bool
+	After    string
 	Checksum int
+	Embed    []string
+	Search   string
+	Headline bool
 }
 
 //ResultSetMeta exported
 type ResultSetMeta struct {
 	Range    string
 	Checksum string
+	Next     string
+	ETag     string
 }
 
 //Model exported
+//Delete is called by Remove, which deletes the row itself when Delete
+//returns ErrDefaultDelete, as DeleteModel does, soft deleting it if
+//the model has a softdelete column. Other errors, nil included, are
+//returned as the outcome of Remove.
 type Model interface {
 	New() Model
 	Table() string
@@ -55,7 +68,7 @@ type NotFoundError msg.Message
 
 //Error exported
 func (e *NotFoundError) Error() string {
-	return e.Error()
+	return msg.Message(*e).String()
 }
 
 //Copy exported
@@ -72,7 +85,7 @@ type NotAllowedError msg.Message
 
 //Error exported
 func (e *NotAllowedError) Error() string {
-	return e.Error()
+	return msg.Message(*e).String()
 }
 
 //Copy exported
@@ -89,7 +102,7 @@ type ConflictError msg.Message
 
 //Error exported
 func (e *ConflictError) Error() string {
-	return e.Error()
+	return msg.Message(*e).String()
 }
 
 //Copy exported
@@ -106,7 +119,7 @@ type ParamError msg.Message
 
 //Error exported
 func (e *ParamError) Error() string {
-	return e.Error()
+	return msg.Message(*e).String()
 }
 
 //Copy exported
@@ -118,6 +131,95 @@ func (e *ParamError) Copy(m msg.Message) {
 	e.Field = m.Field
 }
 
+//SerializationError exported
+//The transaction couldn't be serialized and may be retried
+type SerializationError msg.Message
+
+//Error exported
+func (e *SerializationError) Error() string {
+	return msg.Message(*e).String()
+}
+
+//Copy exported
+func (e *SerializationError) Copy(m msg.Message) {
+
+	e.Key = m.Key
+	e.Msg = m.Msg
+	e.Args = m.Args
+	e.Field = m.Field
+}
+
+//TimeoutError exported
+//The query ran out of time or the client went away
+type TimeoutError msg.Message
+
+//Error exported
+func (e *TimeoutError) Error() string {
+	return msg.Message(*e).String()
+}
+
+//Copy exported
+func (e *TimeoutError) Copy(m msg.Message) {
+
+	e.Key = m.Key
+	e.Msg = m.Msg
+	e.Args = m.Args
+	e.Field = m.Field
+}
+
+//MediaTypeError exported
+type MediaTypeError msg.Message
+
+//Error exported
+func (e *MediaTypeError) Error() string {
+	return msg.Message(*e).String()
+}
+
+//Copy exported
+func (e *MediaTypeError) Copy(m msg.Message) {
+
+	e.Key = m.Key
+	e.Msg = m.Msg
+	e.Args = m.Args
+	e.Field = m.Field
+}
+
+//PreconditionError exported
+//The row version didn't match the one expected
+type PreconditionError msg.Message
+
+//Error exported
+func (e *PreconditionError) Error() string {
+	return msg.Message(*e).String()
+}
+
+//Copy exported
+func (e *PreconditionError) Copy(m msg.Message) {
+
+	e.Key = m.Key
+	e.Msg = m.Msg
+	e.Args = m.Args
+	e.Field = m.Field
+}
+
+//PreconditionRequiredError exported
+//The row has a version but none was expected
+type PreconditionRequiredError msg.Message
+
+//Error exported
+func (e *PreconditionRequiredError) Error() string {
+	return msg.Message(*e).String()
+}
+
+//Copy exported
+func (e *PreconditionRequiredError) Copy(m msg.Message) {
+
+	e.Key = m.Key
+	e.Msg = m.Msg
+	e.Args = m.Args
+	e.Field = m.Field
+}
+
 /*
  * Model interface implementation example
  *
@@ -173,47 +275,52 @@ func (e *ParamError) Copy(m msg.Message) {
  */
 
 //Meta exported
+//The metadata of a model type, computed once by the registry
+//and shared, it must not be modified
 type Meta struct {
-	Ordered  []string
-	Primary  []string
-	Serial   []string
-	View     []string
-	Writable []string
+	Ordered    []string
+	Primary    []string
+	Serial     []string
+	View       []string
+	Writable   []string
+	SoftDelete string
+	Version    string
+	Updated    string
+	Search     []string
+	Vector     string
+	//json names by column
+	JSON map[string]string
+	//struct field names by column
+	Field map[string]string
+	//tag values by tag name and struct field name
+	Tags map[string]map[string]string
+}
+
+//Tagged exported
+//Returns the columns whose fields have tag set to value
+func (meta Meta) Tagged(tag string, value string) (cols []string) {
+
+	for _, k := range meta.Ordered {
+		if v, ok := meta.Tags[tag][meta.Field[k]]; ok && v == value {
+			cols = append(cols, k)
+		}
+	}
+	return
 }
 
 //Fields exported
 func Fields(m Model) (meta Meta, val map[string]interface{}) {
 
 	var (
-		v = reflect.ValueOf(m.Val())
-		t = reflect.Indirect(v)
+		r = lookup(m)
+		v = reflect.Indirect(reflect.ValueOf(m.Val()))
 	)
 
-	val = make(map[string]interface{})
-
-	for i := 0; i < t.NumField(); i++ {
-		k, ok := t.Type().Field(i).Tag.Lookup("db")
-		if ok && k != "-" {
-			val[k] = v.Field(i).Interface()
-			meta.Ordered = append(meta.Ordered, k)
-			//check for primary
-			if primary, _ := t.Type().Field(i).Tag.Lookup("primary"); primary == "1" {
-				meta.Primary = append(meta.Primary, k)
-				//pID = append(pID, lib.Pair{A: k, B: fmt.Sprintf("%v", val[k])})
-			}
-			//check for serial
-			if serial, _ := t.Type().Field(i).Tag.Lookup("serial"); serial == "1" {
-				meta.Serial = append(meta.Serial, k)
-			}
-			//check for view or writable
-			if view, ok := t.Type().Field(i).Tag.Lookup("view"); !ok {
-				meta.Writable = append(meta.Writable, k)
-			} else if view == "1" {
-				meta.View = append(meta.View, k)
-			}
-		}
+	val = make(map[string]interface{}, len(r.index))
+	for i, k := range r.meta.Ordered {
+		val[k] = v.Field(r.index[i]).Interface()
 	}
-	return
+	return r.meta, val
 }
 
 /*
@@ -257,23 +364,25 @@ func Cols(m Model) (cols map[string]interface{}, colsOrdered []string, pk []stri
 
 var db *sql.DB
 
-//Init tests the db connection and saves the db handler
-func Init() error {
+//Init tests the db connection, saves the db handler and
+//verifies the models given against the db schema
+func Init(models ...Model) error {
 
 	var (
 		err error
 		c   = config.Config()
-		//conn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-		conn = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-			c.GetString("db.user"),
-			c.GetString("db.password"),
-			c.GetString("db.host"),
-			c.GetString("db.port"),
-			c.GetString("db.name"))
 	)
 
-	//db, err = sql.Open("mysql", conn)
-	db, err = sql.Open("postgres", conn)
+	if c.IsSet("db.driver") {
+		d, ok := dialects[c.GetString("db.driver")]
+		if !ok {
+			//Unsupported db driver %s
+			return msg.Get("32").SetArgs(c.GetString("db.driver")).M2E()
+		}
+		dialect = d
+	}
+
+	db, err = sql.Open(dialect.Driver(), dialect.DSN(c))
 	if err != nil {
 		//Server error: %s
 		return msg.Get("25").SetArgs(err.Error()).M2E()
@@ -287,7 +396,10 @@ func Init() error {
 
 	db.SetMaxOpenConns(c.GetInt("db.max_open_conns"))
 
-	return nil
+	if err = Register(models...); err != nil {
+		return err
+	}
+	return Verify(models...)
 }
 
 //Db returns the db handler
@@ -297,21 +409,35 @@ func Db() *sql.DB {
 }
 
 //PID exported
+//Returns the primary key of m, none if any of it is nil. f is unused,
+//see PrimaryKey for the column missing.
 func PID(m Model, f []string) (pID []lib.Pair) {
 
+	pID, _ = PrimaryKey(m)
+	return
+}
+
+//PrimaryKey exported
+//Returns the primary key of m, a ParamError if any of it is nil.
+func PrimaryKey(m Model) (pID []lib.Pair, err error) {
+
 	var (
-		v = reflect.ValueOf(m.Val())
-		t = reflect.Indirect(v)
+		r = lookup(m)
+		v = reflect.Indirect(reflect.ValueOf(m.Val()))
 	)
 
-	for i := 0; i < t.NumField(); i++ {
-		k, ok := t.Type().Field(i).Tag.Lookup("primary")
-		if ok && k == "1" {
-			c, _ := t.Type().Field(i).Tag.Lookup("db")
-			pID = append(pID, lib.Pair{A: c, B: fmt.Sprintf("%v", v.Field(i).Interface())})
+	for i, k := range r.meta.Ordered {
+		if !slice.Contains(r.meta.Primary, k) {
+			continue
+		} else if fv := v.Field(r.index[i]); fv.Kind() == reflect.Ptr && fv.IsNil() {
+			e := new(ParamError)
+			e.Copy(msg.Get("62").SetArgs(k)) //Primary key %s is missing
+			return nil, e
+		} else {
+			pID = append(pID, lib.Pair{A: k, B: fmt.Sprintf("%v", reflect.Indirect(fv))})
 		}
 	}
-	return
+	return pID, nil
 }
 
 //MID exported
@@ -327,17 +453,9 @@ func MIDs(k string, v string) []lib.Pair {
 //TAG exported
 func TAG(m Model, tag string) (out map[string]string) {
 
-	var (
-		v = reflect.ValueOf(m.Val())
-		t = reflect.Indirect(v)
-	)
-
 	out = make(map[string]string)
-
-	for i := 0; i < t.NumField(); i++ {
-		if k, ok := t.Type().Field(i).Tag.Lookup(tag); ok {
-			out[t.Type().Field(i).Name] = k
-		}
+	for f, v := range lookup(m).meta.Tags[tag] {
+		out[f] = v
 	}
 	return
 }
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/msg"
+)
+
+//ErrDefaultDelete exported
+//Returned by Model.Delete implementations leaving the job to Delete
+var ErrDefaultDelete = errors.New("default delete")
+
+//DeleteModel exported
+//Embed it in a model to have its rows removed by Delete
+type DeleteModel struct{}
+
+//Delete exported
+func (DeleteModel) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return ErrDefaultDelete
+}
+
+//Remove exported
+//Deletes the row through Delete, soft deleting it if m has a softdelete
+//column, if its Model.Delete returns ErrDefaultDelete, i.e. by embedding
+//DeleteModel, otherwise the Model.Delete outcome is returned. Versioned
+//rows must meet If-Match before Model.Delete is called.
+func Remove(c *gin.Context, m Model, pIDs []lib.Pair) error {
+
+	if match, err := IfMatch(c, m); err != nil {
+		return err
+	} else if err := precondition(c, m, pIDs, match); err != nil {
+		return err
+	} else if err := m.Delete(c, pIDs); err != ErrDefaultDelete {
+		return err
+	}
+	return Delete(c, m, pIDs)
+}
+
+//Delete exported
+//Deletes the scoped row, soft deleting it if m has a softdelete column.
+//Model.Scope gets a *sqlbuilder.DeleteBuilder here. Returns NotFoundError
+//if no row was deleted, and ConflictError naming the foreign key if
+//the row is still referenced. Versioned rows are deleted as in Update.
+func Delete(c *gin.Context, m Model, pIDs []lib.Pair) error {
+
+	var (
+		fields, _ = Fields(m)
+		table     = m.Table()
+		dlb       = Flavor().NewDeleteBuilder()
+	)
+
+	if fields.SoftDelete != "" {
+		return SoftDelete(c, m, pIDs)
+	}
+
+	match, err := IfMatch(c, m)
+	if err != nil {
+		return err
+	}
+
+	dlb.DeleteFrom(table)
+
+	m.Scope(dlb, c)
+
+	for _, p := range pIDs {
+		dlb.Where(dlb.Equal(p.A.(string), p.B.(string)))
+	}
+
+	if e := under(c, m, &dlb.Cond, table); e != "" {
+		dlb.Where(e)
+	}
+
+	if e, err := versioned(&dlb.Cond, m, match); err != nil {
+		return err
+	} else if e != "" {
+		dlb.Where(e)
+	}
+
+	ctx, cancel := Context(c, m)
+	defer cancel()
+
+	sql, args := dlb.Build()
+	//fmt.Println(sql, args)
+	if res, err := Conn(c).ExecContext(ctx, sql, args...); err != nil {
+		if v, ok := dialect.Violation(err); ok && v.Kind == "fk" {
+			e := new(ConflictError)
+			e.Copy(msg.Get("38").SetArgs(v.Constraint)) //Row is referenced by %s
+			return e
+		}
+		//Server error: %s
+		return fail(c, err)
+	} else if rows, _ := res.RowsAffected(); rows == 0 {
+		//not found, out of scope or version mismatch
+		return missed(c, m, pIDs, match)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zicare/go-rpg/lib"
+)
+
+func TestRemoveChecksIfMatch(t *testing.T) {
+
+	var deleted bool
+
+	itemDelete = func() error {
+		deleted = true
+		return nil
+	}
+	defer func() { itemDelete = nil }()
+
+	items(t, "a")
+
+	for _, tc := range []struct {
+		match   string
+		deleted bool
+		err     error
+	}{
+		{"", false, new(PreconditionRequiredError)},
+		{`"2"`, false, new(PreconditionError)},
+		{`"1"`, true, nil},
+	} {
+		deleted = false
+		c := request("DELETE", "/items/1", "If-Match", tc.match)
+		err := Remove(c, new(item), []lib.Pair{{A: "id", B: "1"}})
+		if deleted != tc.deleted {
+			t.Errorf("%s: custom delete called %v, want %v", tc.match, deleted, tc.deleted)
+		} else if tc.err == nil && err != nil {
+			t.Errorf("%s: got %T, want no error", tc.match, err)
+		} else if tc.err != nil && fmt.Sprintf("%T", err) != fmt.Sprintf("%T", tc.err) {
+			t.Errorf("%s: got %T, want %T", tc.match, err, tc.err)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+
+	items(t, "a", "b")
+	schema(t,
+		`CREATE TABLE labels (id INTEGER PRIMARY KEY, item_id INTEGER REFERENCES items(id))`,
+		`INSERT INTO labels (item_id) VALUES (2)`,
+	)
+	defer schema(t, "DROP TABLE labels")
+
+	for _, tc := range []struct {
+		id  string
+		err error
+		key string
+	}{
+		{"1", nil, ""},
+		{"1", new(NotFoundError), "18"},
+		{"9", new(NotFoundError), "18"},
+		{"2", new(ConflictError), "38"},
+	} {
+		c := request("DELETE", "/items/"+tc.id, "If-Match", "*")
+		err := Delete(c, new(item), []lib.Pair{{A: "id", B: tc.id}})
+		if tc.err == nil && err != nil {
+			t.Errorf("%s: got %v, want no error", tc.id, err)
+		} else if tc.err != nil && fmt.Sprintf("%T", err) != fmt.Sprintf("%T", tc.err) {
+			t.Errorf("%s: got %T, want %T", tc.id, err, tc.err)
+		} else if e, ok := err.(*NotFoundError); ok && e.Key != tc.key {
+			t.Errorf("%s: got message %s, want %s", tc.id, e.Key, tc.key)
+		} else if e, ok := err.(*ConflictError); ok && e.Key != tc.key {
+			t.Errorf("%s: got message %s, want %s", tc.id, e.Key, tc.key)
+		}
+	}
+}
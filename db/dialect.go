@@ -0,0 +1,560 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/lib/pq"
+	"github.com/spf13/viper"
+)
+
+//Dialect exported
+//Supplies what's specific to a database engine, chosen with db.driver.
+//The postgres driver is built in, the application must import any other,
+//i.e. _ "github.com/go-sql-driver/mysql" or _ "github.com/mattn/go-sqlite3"
+type Dialect interface {
+	//Driver returns the database/sql driver name
+	Driver() string
+	//Flavor returns the sql builder flavor
+	Flavor() sqlbuilder.Flavor
+	//DSN returns the data source name from the db.* settings
+	DSN(c *viper.Viper) string
+	//Returning tells if INSERT ... RETURNING is supported,
+	//otherwise the serial key is taken from LastInsertId
+	Returning() bool
+	//ForUpdate tells if SELECT ... FOR UPDATE locks the rows read
+	ForUpdate() bool
+	//Upsert runs the insert as one updating instead the row clashing on
+	//conflict, setting the columns in set to the values in to, if cond
+	//holds on it. Tells if the row was inserted or updated, neither if
+	//cond didn't hold. Runs within a transaction.
+	Upsert(ctx context.Context, conn Querier, ib *sqlbuilder.InsertBuilder, table string,
+		conflict []string, set []string, to []interface{}, cond sqlbuilder.Builder) (inserted bool, updated bool, err error)
+	//Violation tells if err is a constraint violation and which one
+	Violation(err error) (Violation, bool)
+	//Retry tells if err is a serialization failure worth a retry
+	Retry(err error) bool
+	//Timeout tells if err is a statement canceled on timeout
+	Timeout(err error) bool
+	//Search returns the expressions matching the text in cols, or the
+	//full-text column vector if set, against q, ranking the matches and
+	//highlighting them. Rank and headline are empty if unsupported.
+	Search(cb *sqlbuilder.Cond, cols []string, vector string, q string) (match string, rank string, headline string)
+	//Describe returns the columns of a table or view by name,
+	//none if it doesn't exist
+	Describe(ctx context.Context, conn *sql.DB, table string) (map[string]Column, error)
+	//Lock takes the advisory lock named on conn, waiting for it,
+	//unlock releases it
+	Lock(ctx context.Context, conn *sql.Conn, name string) (unlock func() error, err error)
+}
+
+//Violation exported
+//A constraint violation reported by the db. Kind is one of
+//unique, notnull, check or fk. Fields the engine doesn't
+//report are left empty.
+type Violation struct {
+	Kind       string
+	Constraint string
+	Table      string
+	Column     string
+	Detail     string
+}
+
+//Column exported
+//A column as described by the db. Type is the engine type name,
+//i.e. varchar(255). View tells the column belongs to a view, which
+//don't tell nullability nor primary keys.
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Primary  bool
+	View     bool
+}
+
+var (
+	dialect  Dialect = postgres{}
+	dialects         = map[string]Dialect{
+		"postgres": postgres{},
+		"mysql":    mysql{},
+		"sqlite3":  sqlite{},
+	}
+)
+
+//AddDialect exported
+//Makes d available as db.driver name
+func AddDialect(name string, d Dialect) {
+	dialects[name] = d
+}
+
+//onConflict returns the ON CONFLICT clause of an upsert, cond is checked
+//in a subquery as columns alone may refer to the excluded row too
+func onConflict(ib *sqlbuilder.InsertBuilder, table string, conflict []string, set []string, to []interface{}, cond sqlbuilder.Builder) string {
+
+	var asg []string
+	for i, col := range set {
+		asg = append(asg, col+" = "+ib.Var(to[i]))
+	}
+	if len(asg) == 0 {
+		//the conflict columns already match
+		asg = append(asg, conflict[0]+" = excluded."+conflict[0])
+	}
+
+	return "ON CONFLICT (" + strings.Join(conflict, ", ") + ") DO UPDATE SET " + strings.Join(asg, ", ") +
+		" WHERE EXISTS (SELECT 1 FROM " + table + " WHERE " + ib.Var(cond) + ")"
+}
+
+//Lock exported
+//Takes the advisory lock named on conn with the configured dialect
+func Lock(ctx context.Context, conn *sql.Conn, name string) (func() error, error) {
+	return dialect.Lock(ctx, conn, name)
+}
+
+//Describe exported
+//Returns the columns of a table or view by name with the configured
+//dialect, none if it doesn't exist
+func Describe(ctx context.Context, conn *sql.DB, table string) (map[string]Column, error) {
+	return dialect.Describe(ctx, conn, table)
+}
+
+//Flavor exported
+//Returns the sql builder flavor of the configured dialect
+func Flavor() sqlbuilder.Flavor {
+	return dialect.Flavor()
+}
+
+type postgres struct{}
+
+func (postgres) Driver() string {
+	return "postgres"
+}
+
+func (postgres) Flavor() sqlbuilder.Flavor {
+	return sqlbuilder.PostgreSQL
+}
+
+func (postgres) DSN(c *viper.Viper) string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		c.GetString("db.user"),
+		c.GetString("db.password"),
+		c.GetString("db.host"),
+		c.GetString("db.port"),
+		c.GetString("db.name"))
+}
+
+func (postgres) Returning() bool {
+	return true
+}
+
+func (postgres) ForUpdate() bool {
+	return true
+}
+
+//xmax is only set on rows updated
+func (postgres) Upsert(ctx context.Context, conn Querier, ib *sqlbuilder.InsertBuilder, table string,
+	conflict []string, set []string, to []interface{}, cond sqlbuilder.Builder) (bool, bool, error) {
+
+	var inserted bool
+
+	ib.SQL(onConflict(ib, table, conflict, set, to, cond) + " RETURNING xmax = 0")
+	q, args := ib.Build()
+	if err := conn.QueryRowContext(ctx, q, args...).Scan(&inserted); err == sql.ErrNoRows {
+		return false, false, nil
+	} else if err != nil {
+		return false, false, err
+	}
+	return inserted, !inserted, nil
+}
+
+func (postgres) Violation(err error) (Violation, bool) {
+
+	var kinds = map[pq.ErrorCode]string{
+		"23505": "unique",
+		"23502": "notnull",
+		"23514": "check",
+		"23503": "fk",
+	}
+
+	pe, ok := err.(*pq.Error)
+	if !ok {
+		return Violation{}, false
+	} else if kind, ok := kinds[pe.Code]; ok {
+		return Violation{
+			Kind:       kind,
+			Constraint: pe.Constraint,
+			Table:      pe.Table,
+			Column:     pe.Column,
+			Detail:     pe.Detail,
+		}, true
+	}
+	return Violation{}, false
+}
+
+func (postgres) Retry(err error) bool {
+	pe, ok := err.(*pq.Error)
+	return ok && pe.Code == "40001"
+}
+
+func (postgres) Timeout(err error) bool {
+	pe, ok := err.(*pq.Error)
+	return ok && pe.Code == "57014"
+}
+
+//websearch_to_tsquery takes the query as typed in search boxes,
+//i.e. "exact phrase" -excluded or alternative
+func (postgres) Search(cb *sqlbuilder.Cond, cols []string, vector string, q string) (match string, rank string, headline string) {
+
+	var (
+		query = "websearch_to_tsquery(" + cb.Var(q) + ")"
+		text  = "concat_ws(' ', " + strings.Join(cols, ", ") + ")"
+		doc   = "to_tsvector(" + text + ")"
+	)
+
+	if vector != "" {
+		doc = vector
+	}
+	if len(cols) > 0 {
+		headline = "ts_headline(" + text + ", " + query + ")"
+	}
+	return doc + " @@ " + query, "ts_rank(" + doc + ", " + query + ")", headline
+}
+
+func (postgres) Describe(ctx context.Context, conn *sql.DB, table string) (map[string]Column, error) {
+
+	rows, err := conn.QueryContext(ctx, `SELECT a.attname, format_type(a.atttypid, a.atttypmod),
+		NOT a.attnotnull, COALESCE(a.attnum = ANY(i.indkey), false), r.relkind IN ('v', 'm')
+		FROM pg_attribute a
+		JOIN pg_class r ON r.oid = a.attrelid
+		LEFT JOIN pg_index i ON i.indrelid = a.attrelid AND i.indisprimary
+		WHERE a.attrelid = to_regclass($1) AND a.attnum > 0 AND NOT a.attisdropped`, table)
+	return describe(rows, err)
+}
+
+//lock keys are 64 bit integers, hashed from the name
+func (postgres) Lock(ctx context.Context, conn *sql.Conn, name string) (func() error, error) {
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	key := int64(h.Sum64())
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		return err
+	}, nil
+}
+
+type mysql struct{}
+
+func (mysql) Driver() string {
+	return "mysql"
+}
+
+func (mysql) Flavor() sqlbuilder.Flavor {
+	return sqlbuilder.MySQL
+}
+
+func (mysql) DSN(c *viper.Viper) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
+		c.GetString("db.user"),
+		c.GetString("db.password"),
+		c.GetString("db.host"),
+		c.GetString("db.port"),
+		c.GetString("db.name"))
+}
+
+func (mysql) Returning() bool {
+	return false
+}
+
+func (mysql) ForUpdate() bool {
+	return true
+}
+
+//the conflict target is implicit, any unique key clash updates the row.
+//Assignments see the ones before them, so cond is taken once into a
+//session variable by the first one. Rows affected are 1 if inserted,
+//2 if updated and 0 if unchanged, whether cond held or not.
+func (mysql) Upsert(ctx context.Context, conn Querier, ib *sqlbuilder.InsertBuilder, table string,
+	conflict []string, set []string, to []interface{}, cond sqlbuilder.Builder) (bool, bool, error) {
+
+	var (
+		held bool
+		k    = conflict[0]
+		asg  = []string{fmt.Sprintf("%s = IF(@rpg_upsert := (%s), %s, %s)", k, ib.Var(cond), k, k)}
+	)
+
+	for i, col := range set {
+		asg = append(asg, fmt.Sprintf("%s = IF(@rpg_upsert, %s, %s)", col, ib.Var(to[i]), col))
+	}
+
+	ib.SQL("ON DUPLICATE KEY UPDATE " + strings.Join(asg, ", "))
+	q, args := ib.Build()
+	res, err := conn.ExecContext(ctx, q, args...)
+	if err != nil {
+		return false, false, err
+	}
+
+	switch n, _ := res.RowsAffected(); n {
+	case 1:
+		return true, false, nil
+	case 2:
+		return false, true, nil
+	}
+	if err := conn.QueryRowContext(ctx, "SELECT COALESCE(@rpg_upsert, 0)").Scan(&held); err != nil {
+		return false, false, err
+	}
+	return false, held, nil
+}
+
+//the driver isn't imported, so errors are told by their message,
+//i.e. Error 1451 (23000): Cannot delete or update a parent row: a foreign
+//key constraint fails (`db`.`orders`, CONSTRAINT `orders_fk` FOREIGN KEY ...
+func (mysql) Violation(err error) (Violation, bool) {
+
+	var (
+		e     = err.Error()
+		kinds = map[string]string{
+			"Error 1062": "unique",
+			"Error 1048": "notnull",
+			"Error 3819": "check",
+			"Error 1451": "fk",
+			"Error 1452": "fk",
+		}
+	)
+
+	for code, kind := range kinds {
+		if strings.HasPrefix(e, code) {
+			v := Violation{Kind: kind, Detail: e}
+			if i := strings.Index(e, "CONSTRAINT `"); i >= 0 {
+				v.Constraint = strings.SplitN(e[i+12:], "`", 2)[0]
+			} else if i := strings.Index(e, "for key '"); i >= 0 {
+				v.Constraint = strings.SplitN(e[i+9:], "'", 2)[0]
+			} else if i := strings.Index(e, "Column '"); i >= 0 {
+				v.Column = strings.SplitN(e[i+8:], "'", 2)[0]
+			}
+			return v, true
+		}
+	}
+	return Violation{}, false
+}
+
+func (mysql) Retry(err error) bool {
+	return strings.HasPrefix(err.Error(), "Error 1213") ||
+		strings.HasPrefix(err.Error(), "Error 1205")
+}
+
+func (mysql) Timeout(err error) bool {
+	return strings.HasPrefix(err.Error(), "Error 3024") ||
+		strings.HasPrefix(err.Error(), "Error 1317")
+}
+
+//a FULLTEXT index on cols is required, vector isn't supported
+func (mysql) Search(cb *sqlbuilder.Cond, cols []string, vector string, q string) (match string, rank string, headline string) {
+
+	if len(cols) == 0 {
+		return "", "", ""
+	}
+	match = "MATCH (" + strings.Join(cols, ", ") + ") AGAINST (" + cb.Var(q) + " IN NATURAL LANGUAGE MODE)"
+	return match, match, ""
+}
+
+func (mysql) Describe(ctx context.Context, conn *sql.DB, table string) (map[string]Column, error) {
+
+	rows, err := conn.QueryContext(ctx, `SELECT c.column_name, c.column_type,
+		c.is_nullable = 'YES', c.column_key = 'PRI', t.table_type = 'VIEW'
+		FROM information_schema.columns c
+		JOIN information_schema.tables t
+		ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = DATABASE() AND c.table_name = ?`, table)
+	return describe(rows, err)
+}
+
+func (mysql) Lock(ctx context.Context, conn *sql.Conn, name string) (func() error, error) {
+
+	var got sql.NullInt64
+
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&got); err != nil {
+		return nil, err
+	} else if got.Int64 != 1 {
+		return nil, fmt.Errorf("couldn't lock %s", name)
+	}
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+		return err
+	}, nil
+}
+
+type sqlite struct{}
+
+func (sqlite) Driver() string {
+	return "sqlite3"
+}
+
+func (sqlite) Flavor() sqlbuilder.Flavor {
+	return sqlbuilder.SQLite
+}
+
+//db.name is the database file path, or :memory:
+func (sqlite) DSN(c *viper.Viper) string {
+	return c.GetString("db.name")
+}
+
+func (sqlite) Returning() bool {
+	return false
+}
+
+//writers lock the whole database file instead
+func (sqlite) ForUpdate() bool {
+	return false
+}
+
+//rows affected are 1 either way, so cond is checked first. Transactions
+//are serializable, no write gets between both statements unnoticed.
+func (sqlite) Upsert(ctx context.Context, conn Querier, ib *sqlbuilder.InsertBuilder, table string,
+	conflict []string, set []string, to []interface{}, cond sqlbuilder.Builder) (bool, bool, error) {
+
+	var held bool
+
+	q, args := sqlbuilder.Buildf("SELECT EXISTS (SELECT 1 FROM "+table+" WHERE %v)", cond).
+		BuildWithFlavor(sqlbuilder.SQLite)
+	if err := conn.QueryRowContext(ctx, q, args...).Scan(&held); err != nil {
+		return false, false, err
+	}
+
+	ib.SQL(onConflict(ib, table, conflict, set, to, cond))
+	q, args = ib.Build()
+	res, err := conn.ExecContext(ctx, q, args...)
+	if err != nil {
+		return false, false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1 && !held, n == 1 && held, nil
+}
+
+//i.e. UNIQUE constraint failed: users.email
+func (sqlite) Violation(err error) (Violation, bool) {
+
+	var (
+		e     = err.Error()
+		kinds = map[string]string{
+			"UNIQUE constraint failed":      "unique",
+			"NOT NULL constraint failed":    "notnull",
+			"CHECK constraint failed":       "check",
+			"FOREIGN KEY constraint failed": "fk",
+		}
+	)
+
+	for prefix, kind := range kinds {
+		if i := strings.Index(e, prefix); i >= 0 {
+			v := Violation{Kind: kind, Detail: e}
+			rest := strings.TrimPrefix(e[i+len(prefix):], ": ")
+			if kind == "check" {
+				v.Constraint = rest
+			} else if j := strings.Split(strings.Split(rest, ",")[0], "."); len(j) == 2 {
+				v.Table, v.Column = j[0], j[1]
+			}
+			return v, true
+		}
+	}
+	return Violation{}, false
+}
+
+func (sqlite) Retry(err error) bool {
+	return strings.Contains(err.Error(), "database is locked")
+}
+
+func (sqlite) Timeout(err error) bool {
+	return strings.Contains(err.Error(), "interrupted")
+}
+
+//there's no full-text search without the fts5 module, cols are
+//matched with LIKE, vector isn't supported
+func (sqlite) Search(cb *sqlbuilder.Cond, cols []string, vector string, q string) (match string, rank string, headline string) {
+
+	var like []string
+	for _, k := range cols {
+		like = append(like, likeEscaped(cb, k, "%"+escapeLike(q)+"%"))
+	}
+	if len(like) == 0 {
+		return "", "", ""
+	}
+	return cb.Or(like...), "", ""
+}
+
+//primary key columns are taken as not null
+func (sqlite) Describe(ctx context.Context, conn *sql.DB, table string) (map[string]Column, error) {
+
+	rows, err := conn.QueryContext(ctx, `SELECT name, type, "notnull" = 0 AND pk = 0, pk > 0,
+		EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'view' AND name = ?1)
+		FROM pragma_table_info(?1)`, table)
+	return describe(rows, err)
+}
+
+//there are no advisory locks, a write transaction on a db file of
+//its own, next to the one of conn, takes their place, released by the os
+//if the process dies. In-memory dbs aren't shared, there's nothing to lock.
+func (d sqlite) Lock(ctx context.Context, conn *sql.Conn, name string) (func() error, error) {
+
+	var file string
+
+	if err := conn.QueryRowContext(ctx, "SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&file); err != nil {
+		return nil, err
+	} else if file == "" {
+		return func() error { return nil }, nil
+	}
+
+	ldb, err := sql.Open(d.Driver(), file+"-"+name+".lock?_busy_timeout=100")
+	if err != nil {
+		return nil, err
+	}
+	lc, err := ldb.Conn(ctx)
+	if err != nil {
+		ldb.Close()
+		return nil, err
+	}
+
+	//waits for the lock as long as ctx does
+	for {
+		if _, err = lc.ExecContext(ctx, "BEGIN IMMEDIATE"); err == nil {
+			break
+		} else if !d.Retry(err) || ctx.Err() != nil {
+			lc.Close()
+			ldb.Close()
+			return nil, err
+		}
+	}
+
+	return func() error {
+		_, err := lc.ExecContext(context.Background(), "ROLLBACK")
+		lc.Close()
+		ldb.Close()
+		return err
+	}, nil
+}
+
+//describe reads the name, type, nullable, primary and view
+//columns of rows into columns by name
+func describe(rows *sql.Rows, err error) (map[string]Column, error) {
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := make(map[string]Column)
+	for rows.Next() {
+		var c Column
+		if err := rows.Scan(&c.Name, &c.Type, &c.Nullable, &c.Primary, &c.View); err != nil {
+			return nil, err
+		}
+		cols[c.Name] = c
+	}
+	return cols, rows.Err()
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+ * Relationship embedding
+ *
+ * Fields not mapped to a column, db:"-", may hold related models that
+ * FetchAll and Find load when named in ?embed=customer,orders. The name
+ * is the json name of the field.
+ *
+ * fk:"table.column" tags a column referencing the column of a table,
+ * a field holding a model of that table holds the row referenced:
+ *
+ *	CustomerID *int64    `db:"customer_id" json:"customer_id" fk:"customers.id"`
+ *	Customer   *Customer `db:"-" json:"customer,omitempty"`
+ *
+ * The field names the column, fk:"customer_id", when several reference
+ * the same table:
+ *
+ *	BillTo   *int64    `db:"bill_to" json:"bill_to" fk:"customers.id"`
+ *	ShipTo   *int64    `db:"ship_to" json:"ship_to" fk:"customers.id"`
+ *	Shipping *Customer `db:"-" json:"shipping,omitempty" fk:"ship_to"`
+ *
+ * has_many:"column" holds the rows whose column references the primary
+ * key of the model:
+ *
+ *	Orders []*Order `db:"-" json:"orders,omitempty" has_many:"customer_id"`
+ *
+ * Related rows are loaded with one query per relation, they're scoped,
+ * soft deleted ones are left out, and transformed with Xfrm.
+ */
+
+//relation is a field of a model holding related models
+type relation struct {
+	field  int
+	local  string
+	remote string
+	many   bool
+	elem   reflect.Type
+}
+
+//related returns the relations of m by name
+func related(m Model) map[string]relation {
+
+	var (
+		t         = reflect.Indirect(reflect.ValueOf(m.Val())).Type()
+		fields, _ = Fields(m)
+		rels      = make(map[string]relation)
+		refs      = make(map[string][]string)
+	)
+
+	//columns referencing other tables, by table
+	for _, k := range fields.Ordered {
+		if ref := fields.Tags["fk"][fields.Field[k]]; strings.LastIndex(ref, ".") > 0 {
+			table := ref[:strings.LastIndex(ref, ".")]
+			refs[table] = append(refs[table], k)
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		var (
+			f       = t.Field(i)
+			r       = relation{field: i, elem: f.Type}
+			name    = strings.Split(fields.Tags["json"][f.Name], ",")[0]
+			fk, one = fields.Tags["fk"][f.Name]
+			hm, hmk = fields.Tags["has_many"][f.Name]
+		)
+
+		if fields.Tags["db"][f.Name] != "-" || name == "" || name == "-" {
+			continue
+		}
+
+		if r.many = hmk; r.many {
+			if f.Type.Kind() != reflect.Slice {
+				continue
+			}
+			r.elem = f.Type.Elem()
+		}
+		if r.elem.Kind() == reflect.Ptr {
+			r.elem = r.elem.Elem()
+		}
+		if r.elem.Kind() != reflect.Struct {
+			continue
+		}
+
+		rm, ok := reflect.New(r.elem).Interface().(Model)
+		if !ok {
+			continue
+		}
+		remote, _ := Fields(rm)
+
+		if r.many {
+			if len(fields.Primary) != 1 {
+				//composite keys aren't supported
+				continue
+			}
+			r.local, r.remote = fields.Primary[0], hm
+		} else {
+			//the column referencing the table of rm, named by fk
+			//if there are several
+			cols := refs[rm.Table()]
+			if rm.View() != rm.Table() {
+				cols = append(append([]string{}, cols...), refs[rm.View()]...)
+			}
+			if one {
+				cols = []string{fk}
+			} else if len(cols) != 1 {
+				continue
+			}
+			ref := fields.Tags["fk"][fields.Field[cols[0]]]
+			dot := strings.LastIndex(ref, ".")
+			if dot < 1 {
+				continue
+			} else if table := ref[:dot]; table != rm.Table() && table != rm.View() {
+				continue
+			}
+			r.local, r.remote = cols[0], ref[dot+1:]
+		}
+		if _, ok := remote.Field[r.remote]; !ok {
+			continue
+		}
+		rels[name] = r
+	}
+	return rels
+}
+
+//embeds returns the relation names of m listed in ?embed=,
+//unknown ones are ignored
+func embeds(c *gin.Context, m Model) (names []string) {
+
+	rels := relations(m)
+	for _, name := range strings.Split(c.Query("embed"), ",") {
+		if _, ok := rels[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+//embed loads the named relations of rows, one query per relation
+func embed(c *gin.Context, rows []Model, names []string) error {
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	rels := relations(rows[0])
+
+	for _, name := range names {
+		var (
+			r    = rels[name]
+			rm   = reflect.New(r.elem).Interface().(Model)
+			vals []interface{}
+			seen = make(map[string]bool)
+		)
+
+		//values referenced by the rows
+		for _, row := range rows {
+			_, val := Fields(row)
+			v := reflect.Indirect(reflect.ValueOf(val[r.local]))
+			if !v.IsValid() {
+				continue
+			} else if k := fmt.Sprintf("%v", v.Interface()); !seen[k] {
+				seen[k] = true
+				vals = append(vals, v.Interface())
+			}
+		}
+		if len(vals) == 0 {
+			continue
+		}
+
+		found, err := fetchIn(c, rm, r.remote, vals)
+		if err != nil {
+			return err
+		}
+
+		//set the related models on every row
+		for _, row := range rows {
+			var (
+				_, val = Fields(row)
+				v      = reflect.Indirect(reflect.ValueOf(val[r.local]))
+				f      = reflect.ValueOf(row).Elem().Field(r.field)
+			)
+			if !v.IsValid() {
+				continue
+			}
+			for _, x := range found[fmt.Sprintf("%v", v.Interface())] {
+				xv := reflect.ValueOf(x)
+				if r.many && !xv.Type().AssignableTo(f.Type().Elem()) {
+					xv = xv.Elem()
+				} else if !r.many && !xv.Type().AssignableTo(f.Type()) {
+					xv = xv.Elem()
+				}
+				if r.many {
+					f.Set(reflect.Append(f, xv))
+				} else {
+					f.Set(xv)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//fetchIn returns the scoped rows of m whose column k holds any of vals,
+//transformed and grouped by the value of k
+func fetchIn(c *gin.Context, m Model, k string, vals []interface{}) (map[string][]Model, error) {
+
+	var (
+		table   = m.View()
+		ms      = Struct(m)
+		sb      = ms.SelectFrom(table)
+		related = make(map[string][]Model)
+	)
+
+	m.Scope(sb, c)
+
+	if e := live(c, m, &sb.Cond, table); e != "" {
+		sb.Where(e)
+	}
+
+	sb.Where(sb.In(fmt.Sprintf("%s.%s", table, k), vals...))
+
+	ctx, cancel := Context(c, m)
+	defer cancel()
+
+	q, args := sb.Build()
+	//fmt.Println(q, args)
+	rows, err := Conn(c).QueryContext(ctx, q, args...)
+	if err != nil {
+		//Server error: %s
+		return nil, fail(c, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := m.New()
+		if err := rows.Scan(ms.Addr(&row)...); err != nil {
+			//Server error: %s
+			return nil, fail(c, err)
+		}
+		_, val := Fields(row)
+		key := fmt.Sprintf("%v", reflect.Indirect(reflect.ValueOf(val[k])).Interface())
+		related[key] = append(related[key], row.Xfrm(c))
+	}
+	if err := rows.Err(); err != nil {
+		//Server error: %s
+		return nil, fail(c, err)
+	}
+	return related, nil
+}
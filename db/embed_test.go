@@ -0,0 +1,176 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/lib"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//hidden authors are out of scope, names are upper cased by Xfrm
+type author struct {
+	ID     *int64  `db:"id"     json:"id"     primary:"1" serial:"1"`
+	Name   *string `db:"name"   json:"name"`
+	Hidden *bool   `db:"hidden" json:"-"`
+	Books  []*book `db:"-"      json:"books,omitempty" has_many:"author_id"`
+}
+
+type book struct {
+	ID       *int64  `db:"id"        json:"id"        primary:"1" serial:"1"`
+	AuthorID *int64  `db:"author_id" json:"author_id" fk:"authors.id"`
+	Title    *string `db:"title"     json:"title"`
+	Author   *author `db:"-"         json:"author,omitempty"`
+}
+
+//authorScopes counts the queries on authors
+var authorScopes int
+
+func (*author) New() Model {
+	return new(author)
+}
+
+func (*author) Table() string {
+	return "authors"
+}
+
+func (*author) View() string {
+	return "authors"
+}
+
+func (a *author) Val() interface{} {
+	return *a
+}
+
+func (a *author) Xfrm(c *gin.Context) Model {
+	if a.Name != nil {
+		a.Name = str(strings.ToUpper(*a.Name))
+	}
+	return a
+}
+
+func (a *author) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*author) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*author) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return ErrDefaultDelete
+}
+
+func (*author) Scope(b sqlbuilder.Builder, c *gin.Context) {
+	if sb, ok := b.(*sqlbuilder.SelectBuilder); ok {
+		authorScopes++
+		sb.Where("authors.hidden = 0")
+	}
+}
+
+func (*book) New() Model {
+	return new(book)
+}
+
+func (*book) Table() string {
+	return "books"
+}
+
+func (*book) View() string {
+	return "books"
+}
+
+func (b *book) Val() interface{} {
+	return *b
+}
+
+func (b *book) Xfrm(c *gin.Context) Model {
+	return b
+}
+
+func (b *book) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*book) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*book) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return ErrDefaultDelete
+}
+
+func (*book) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+func library(t *testing.T) {
+
+	t.Helper()
+	schema(t,
+		"DROP TABLE IF EXISTS books",
+		"DROP TABLE IF EXISTS authors",
+		"CREATE TABLE authors (id INTEGER PRIMARY KEY, name VARCHAR(100), hidden BOOLEAN NOT NULL DEFAULT 0)",
+		"CREATE TABLE books (id INTEGER PRIMARY KEY, author_id INTEGER, title VARCHAR(100))",
+		"INSERT INTO authors (id, name, hidden) VALUES (1, 'ann', 0), (2, 'bob', 0), (3, 'eve', 1)",
+		"INSERT INTO books (id, author_id, title) VALUES (1, 1, 'a'), (2, 2, 'b'), (3, 1, 'c'), (4, 3, 'd'), (5, NULL, 'e')",
+	)
+}
+
+func TestRelations(t *testing.T) {
+
+	rels := relations(new(book))
+	if r, ok := rels["author"]; !ok || r.local != "author_id" || r.remote != "id" || r.many {
+		t.Errorf("author: got %+v", r)
+	}
+	rels = relations(new(author))
+	if r, ok := rels["books"]; !ok || r.local != "id" || r.remote != "author_id" || !r.many {
+		t.Errorf("books: got %+v", r)
+	}
+}
+
+func TestEmbedBelongsTo(t *testing.T) {
+
+	library(t)
+	authorScopes = 0
+
+	_, rows, err := FetchAll(request("GET", "/books?embed=author&order=id"), new(book))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(rows) != 5 {
+		t.Fatalf("got %d books, want 5", len(rows))
+	}
+
+	for i, want := range []string{"ANN", "BOB", "ANN", "", ""} {
+		b := rows[i].(book)
+		switch {
+		case want == "" && b.Author != nil:
+			//out of scope or no author
+			t.Errorf("book %d: got author %s, want none", *b.ID, *b.Author.Name)
+		case want != "" && (b.Author == nil || *b.Author.Name != want):
+			t.Errorf("book %d: got %v, want %s", *b.ID, b.Author, want)
+		}
+	}
+
+	//one query for all the authors
+	if authorScopes != 1 {
+		t.Errorf("authors queried %d times, want once", authorScopes)
+	}
+}
+
+func TestEmbedHasMany(t *testing.T) {
+
+	library(t)
+
+	c := request("GET", "/authors/1?embed=books")
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	a := new(author)
+	if err := Find(c, a); err != nil {
+		t.Fatal(err)
+	} else if len(a.Books) != 2 || *a.Books[0].Title != "a" || *a.Books[1].Title != "c" {
+		t.Errorf("got %d books, want a and c", len(a.Books))
+	}
+
+	//not embedded unless asked
+	c = request("GET", "/authors/1")
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	if a = new(author); Find(c, a) != nil || a.Books != nil {
+		t.Errorf("got %d books, want none", len(a.Books))
+	}
+}
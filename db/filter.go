@@ -0,0 +1,205 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/lib"
+)
+
+//operators FetchAll accepts as query params, i.e. ?ne=status|closed
+var operators = []string{
+	"eq", "ne", "gt", "gteq", "st", "steq",
+	"in", "nin", "like", "ilike", "ieq",
+	"startswith", "endswith", "between",
+}
+
+//FilterGroup exported
+//Filters and nested groups joined by Conj, either "and" or "or".
+//Filter pairs are Triplets with A: operator, B: column, C: value
+type FilterGroup struct {
+	Conj   string
+	Filter []lib.Triplet
+	Group  []FilterGroup
+}
+
+//isOperator tells if op is a supported filter operator
+func isOperator(op string) bool {
+	for _, o := range operators {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+//isOperand tells if v is a valid value for op
+func isOperand(op string, v string) bool {
+	if op == "between" {
+		return len(strings.Split(v, ",")) == 2
+	}
+	return true
+}
+
+//filter returns the where expression for column k and value v.
+//Values holding commas are lists for eq, ne, in and nin,
+//and the lower and upper bounds for between.
+//Other operators take the first element only.
+func filter(cb *sqlbuilder.Cond, op string, k string, v string) string {
+
+	var (
+		p   = strings.Split(v, ",")
+		fst = p[0]
+	)
+
+	switch op {
+	case "eq":
+		if len(p) > 1 {
+			return cb.In(k, sqlbuilder.Flatten(p)...)
+		}
+		return cb.Equal(k, v)
+	case "ne":
+		if len(p) > 1 {
+			return cb.NotIn(k, sqlbuilder.Flatten(p)...)
+		}
+		return cb.NotEqual(k, v)
+	case "gt":
+		return cb.GreaterThan(k, fst)
+	case "gteq":
+		return cb.GreaterEqualThan(k, fst)
+	case "st":
+		return cb.LessThan(k, fst)
+	case "steq":
+		return cb.LessEqualThan(k, fst)
+	case "in":
+		return cb.In(k, sqlbuilder.Flatten(p)...)
+	case "nin":
+		return cb.NotIn(k, sqlbuilder.Flatten(p)...)
+	case "like":
+		return cb.Like(k, v)
+	case "ilike":
+		return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s)", k, cb.Var(v))
+	case "ieq":
+		return fmt.Sprintf("LOWER(%s) = LOWER(%s)", k, cb.Var(v))
+	case "startswith":
+		return likeEscaped(cb, k, escapeLike(v)+"%")
+	case "endswith":
+		return likeEscaped(cb, k, "%"+escapeLike(v))
+	case "between":
+		return cb.Between(k, p[0], p[1])
+	}
+	return ""
+}
+
+//escapeLike escapes LIKE wildcards so v is matched literally. The
+//escape character is !, a backslash means something else to every
+//engine in string literals.
+func escapeLike(v string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(v)
+}
+
+//likeEscaped returns the LIKE expression for k and a pattern
+//escaped by escapeLike
+func likeEscaped(cb *sqlbuilder.Cond, k string, pattern string) string {
+	return fmt.Sprintf("%s LIKE %s ESCAPE '!'", k, cb.Var(pattern))
+}
+
+//group parses a filter group param, i.e.
+//?or=(eq|status|open;eq|assignee|5;and(gt|amount|10;st|amount|100))
+//Items are separated by semicolons, nested groups are and(...) or or(...).
+//Values holding semicolons or parenthesis are double quoted, doubling the
+//quotes they hold, i.e. eq|title|"a;b ""c"" (d)". Commas still separate
+//list values. Items with unknown operators or columns not in val are ignored
+func group(conj string, s string, val map[string]interface{}) (g FilterGroup, ok bool) {
+
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return g, false
+	}
+
+	g.Conj = conj
+	for _, item := range split(s[1:len(s)-1], ';') {
+		if i := strings.Index(item, "("); i > 0 && (item[:i] == "and" || item[:i] == "or") {
+			if sg, ok := group(item[:i], item[i:], val); ok {
+				g.Group = append(g.Group, sg)
+			}
+		} else if j := strings.SplitN(item, "|", 3); len(j) != 3 {
+			continue
+		} else if v, ok := unquote(j[2]); !ok {
+			continue
+		} else if _, ok := val[j[1]]; ok && isOperator(j[0]) && isOperand(j[0], v) {
+			g.Filter = append(g.Filter, lib.Triplet{A: j[0], B: j[1], C: v})
+		}
+	}
+	return g, len(g.Filter)+len(g.Group) > 0
+}
+
+//unquote returns v without the double quotes around it, if any,
+//telling if v is well formed
+func unquote(v string) (string, bool) {
+
+	if !strings.HasPrefix(v, `"`) {
+		return v, !strings.Contains(v, `"`)
+	} else if len(v) < 2 || !strings.HasSuffix(v, `"`) {
+		return v, false
+	}
+
+	v = v[1 : len(v)-1]
+	if strings.Count(v, `"`) != 2*strings.Count(v, `""`) {
+		//quotes within must be doubled
+		return v, false
+	}
+	return strings.Replace(v, `""`, `"`, -1), true
+}
+
+//split splits s by sep, ignoring separators within
+//parenthesis or double quotes
+func split(s string, sep byte) (out []string) {
+
+	var (
+		depth, from int
+		quoted      bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case quoted:
+		case s[i] == '(':
+			depth++
+		case s[i] == ')':
+			depth--
+		case s[i] == sep && depth == 0:
+			out = append(out, s[from:i])
+			from = i + 1
+		}
+	}
+	return append(out, s[from:])
+}
+
+//where returns the grouped where expression for g,
+//columns are prefixed with table
+func (g FilterGroup) where(cb *sqlbuilder.Cond, table string) string {
+
+	var expr []string
+
+	for _, f := range g.Filter {
+		k := fmt.Sprintf("%s.%s", table, f.B.(string))
+		expr = append(expr, filter(cb, f.A.(string), k, f.C.(string)))
+	}
+	for _, sg := range g.Group {
+		if e := sg.where(cb, table); e != "" {
+			expr = append(expr, e)
+		}
+	}
+
+	switch {
+	case len(expr) == 0:
+		return ""
+	case g.Conj == "or":
+		return cb.Or(expr...)
+	default:
+		return "(" + strings.Join(expr, " AND ") + ")"
+	}
+}
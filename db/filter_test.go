@@ -0,0 +1,91 @@
+package db
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/zicare/go-rpg/lib"
+)
+
+func TestStartsWithEscapesWildcards(t *testing.T) {
+
+	items(t, "50%_off", "50xyoff", `50!%_x`)
+
+	for prefix, want := range map[string]int{
+		"50%_": 1,
+		"50":   3,
+		"50!%": 1,
+		"5_":   0,
+	} {
+		c := request("GET", "/items?startswith="+url.QueryEscape("name|"+prefix))
+		_, rows, err := FetchAll(c, new(item))
+		if err != nil {
+			t.Fatal(err)
+		} else if len(rows) != want {
+			t.Errorf("startswith %q: got %d rows, want %d", prefix, len(rows), want)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+
+	val := map[string]interface{}{"status": nil, "title": nil, "amount": nil}
+
+	for _, tc := range []struct {
+		in   string
+		want FilterGroup
+		ok   bool
+	}{
+		{
+			in: `(eq|status|open;and(gt|amount|10;st|amount|100))`,
+			want: FilterGroup{Conj: "or",
+				Filter: []lib.Triplet{{A: "eq", B: "status", C: "open"}},
+				Group: []FilterGroup{{Conj: "and", Filter: []lib.Triplet{
+					{A: "gt", B: "amount", C: "10"},
+					{A: "st", B: "amount", C: "100"},
+				}}},
+			},
+			ok: true,
+		},
+		{
+			in: `(eq|title|"a;b (c)";eq|status|open)`,
+			want: FilterGroup{Conj: "or", Filter: []lib.Triplet{
+				{A: "eq", B: "title", C: "a;b (c)"},
+				{A: "eq", B: "status", C: "open"},
+			}},
+			ok: true,
+		},
+		{
+			in: `(eq|title|"say ""hi"";)")`,
+			want: FilterGroup{Conj: "or", Filter: []lib.Triplet{
+				{A: "eq", B: "title", C: `say "hi";)`},
+			}},
+			ok: true,
+		},
+		{
+			//unknown columns and operators, malformed quotes
+			in:   `(eq|nope|1;zz|status|1;eq|title|"open;eq|title|a"b)`,
+			want: FilterGroup{Conj: "or"},
+		},
+		{in: `eq|status|open`},
+	} {
+		g, ok := group("or", tc.in, val)
+		if ok != tc.ok || !reflect.DeepEqual(g, tc.want) && tc.ok {
+			t.Errorf("%s: got %+v %v, want %+v %v", tc.in, g, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestGroupQuery(t *testing.T) {
+
+	items(t, "a;b", "c)d", "e")
+
+	c := request("GET", "/items?or="+url.QueryEscape(`(eq|name|"a;b";eq|name|"c)d")`))
+	_, rows, err := FetchAll(c, new(item))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(rows) != 2 {
+		t.Errorf("got %d rows, want 2", len(rows))
+	}
+}
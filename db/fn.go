@@ -1,6 +1,9 @@
 package db
 
 import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -38,27 +41,37 @@ func ParamIDs(c *gin.Context, m Model) ([]lib.Pair, error) {
 func params(c *gin.Context, m Model) (opts SelectOpt) {
 
 	var (
-		cf          = [5]string{"eq", "gt", "st", "gteq", "steq"}
 		fields, val = Fields(m)
 		param       = c.Request.URL.Query()
 	)
 
 	//filter
 	opts.Filter = make(map[string][]lib.Pair)
-	for _, fi := range cf {
+	for _, fi := range operators {
 		opts.Filter[fi] = []lib.Pair{}
 		if i, ok := param[fi]; ok {
 			//j := strings.Split(i[0], ";")
 			for _, k := range i {
-				j := strings.Split(k, "|")
-				_, ok := val[j[0]]
-				if ok {
+				j := strings.SplitN(k, "|", 2)
+				if len(j) != 2 || !isOperand(fi, j[1]) {
+					continue
+				} else if _, ok := val[j[0]]; ok {
 					opts.Filter[fi] = append(opts.Filter[fi], lib.Pair{A: j[0], B: j[1]})
 				}
 			}
 		}
 	}
 
+	//filter groups
+	opts.Group = []FilterGroup{}
+	for _, conj := range []string{"and", "or"} {
+		for _, k := range param[conj] {
+			if g, ok := group(conj, k, val); ok {
+				opts.Group = append(opts.Group, g)
+			}
+		}
+	}
+
 	/*
 		//scope filter
 		if sm, ok := m.(ScopedModel); ok == true {
@@ -149,10 +162,106 @@ func params(c *gin.Context, m Model) (opts SelectOpt) {
 		}
 	}
 
+	//cursor, ?after= with no value requests the first page
+	if i, ok := param["after"]; ok {
+		opts.Cursor = true
+		opts.After = i[0]
+	}
+
 	//checksum
 	if c.Query("checksum") == "1" {
 		opts.Checksum = 1
 	}
 
+	//embed
+	opts.Embed = embeds(c, m)
+
+	//full-text search
+	opts.Search = c.Query("q")
+	opts.Headline = c.Query("headline") == "1"
+
+	return
+}
+
+//assign sets the field dst points to from its string representation
+func assign(dst interface{}, src string) error {
+
+	v := reflect.ValueOf(dst).Elem()
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(src)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(src, 10, 64); err == nil {
+			v.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(src, 10, 64); err == nil {
+			v.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var n float64
+		if n, err = strconv.ParseFloat(src, 64); err == nil {
+			v.SetFloat(n)
+		}
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(src); err == nil {
+			v.SetBool(b)
+		}
+	default:
+		err = strconv.ErrSyntax
+	}
+
+	if err != nil {
+		//Value is a %s, required type is %s
+		return msg.Get("23").SetArgs(src, v.Type().String()).M2E()
+	}
+	return nil
+}
+
+//rebind replaces the request body with a json document,
+//so it can be bound again
+func rebind(c *gin.Context, body []byte) {
+
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+}
+
+//isNull tells if v is nil or a nil pointer
+func isNull(v interface{}) bool {
+
+	rv := reflect.ValueOf(v)
+	return !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil())
+}
+
+//Columns exported
+//Returns the json names of the columns FetchAll and Find output for the
+//request, as selected by ?cols= and ?xcols=, in the order of m, followed
+//by the relations in ?embed= and the ?headline=1 member
+func Columns(c *gin.Context, m Model) (names []string) {
+
+	var (
+		opt       = params(c, m)
+		fields, _ = Fields(m)
+	)
+
+	for _, k := range opt.Column {
+		if j, ok := fields.JSON[k]; ok {
+			names = append(names, j)
+		}
+	}
+	names = append(names, opt.Embed...)
+	if opt.Search != "" && opt.Headline {
+		names = append(names, "headline")
+	}
 	return
 }
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/zicare/go-rpg/config"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/msg"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//tests run against a sqlite db in a temporary directory
+func TestMain(m *testing.M) {
+
+	dir, err := os.MkdirTemp("", "go-rpg")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Mkdir(filepath.Join(dir, "config"), 0755)
+	os.WriteFile(filepath.Join(dir, "config", "test.json"), []byte(`{
+		"db": {"driver": "sqlite3", "name": "`+filepath.Join(dir, "test.db")+`?_busy_timeout=5000&_fk=1"},
+		"param": {"icpp": "25"}
+	}`), 0644)
+
+	gin.SetMode(gin.TestMode)
+
+	if err := config.Init("test", dir); err != nil {
+		panic(err)
+	} else if err := msg.Init(nil); err != nil {
+		panic(err)
+	} else if err := Init(); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	db.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+//schema runs the statements given, failing t on error
+func schema(t testing.TB, stmts ...string) {
+
+	t.Helper()
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+}
+
+//request returns the context of a request with the method, target
+//and headers given, in pairs
+func request(method string, target string, headers ...string) *gin.Context {
+
+	return send(method, target, "", headers...)
+}
+
+//send is request with a body
+func send(method string, target string, body string, headers ...string) *gin.Context {
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		c.Request.Header.Set(headers[i], headers[i+1])
+	}
+	return c
+}
+
+//str returns a pointer to s
+func str(s string) *string {
+	return &s
+}
+
+//item is the model of the items table tests share
+type item struct {
+	ID      *int64  `db:"id"      json:"id"      primary:"1" serial:"1"`
+	Name    *string `db:"name"    json:"name"`
+	Version *int64  `db:"version" json:"version" version:"1"`
+}
+
+const itemTable = `CREATE TABLE IF NOT EXISTS items (
+	id      INTEGER PRIMARY KEY AUTOINCREMENT,
+	name    VARCHAR(100),
+	version INTEGER NOT NULL DEFAULT 1
+)`
+
+func (*item) New() Model {
+	return new(item)
+}
+
+func (*item) Table() string {
+	return "items"
+}
+
+func (*item) View() string {
+	return "items"
+}
+
+func (i *item) Val() interface{} {
+	return *i
+}
+
+func (i *item) Xfrm(c *gin.Context) Model {
+	return i
+}
+
+func (i *item) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return c.ShouldBind(i)
+}
+
+func (*item) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+//itemDelete, if set, deletes items instead of Delete
+var itemDelete func() error
+
+func (*item) Delete(c *gin.Context, pIDs []lib.Pair) error {
+
+	if itemDelete != nil {
+		return itemDelete()
+	}
+	return ErrDefaultDelete
+}
+
+func (*item) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+//items empties the items table and inserts the names given,
+//numbered from 1
+func items(t testing.TB, names ...string) {
+
+	t.Helper()
+	schema(t, itemTable, "DELETE FROM items", "DELETE FROM sqlite_sequence WHERE name = 'items'")
+	for _, name := range names {
+		if _, err := db.Exec("INSERT INTO items (name) VALUES (?)", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package migrate
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed sql
+var bundled embed.FS
+
+//ACL exported
+//Migrations creating the acl table acl.Init reads, a model for it being
+//
+//	type Grant struct {
+//		RoleID *int64     `db:"role_id"     json:"role_id"     acl:"role"   primary:"1"`
+//		Route  *string    `db:"route"       json:"route"       acl:"route"  primary:"1"`
+//		Method *string    `db:"method"      json:"method"      acl:"method" primary:"1"`
+//		From   *time.Time `db:"access_from" json:"access_from" acl:"from"`
+//		To     *time.Time `db:"access_to"   json:"access_to"   acl:"to"`
+//	}
+func ACL() []Migration {
+	return must("sql/acl")
+}
+
+//CORS exported
+//Migrations creating the cors table cors.Init reads, a model for it being
+//
+//	type App struct {
+//		Key    *string `db:"app_key"    json:"app_key"    cors:"key" primary:"1"`
+//		Origin *string `db:"app_origin" json:"app_origin" cors:"origin"`
+//	}
+func CORS() []Migration {
+	return must("sql/cors")
+}
+
+//must returns the bundled migrations in dir, which are known to load
+func must(dir string) []Migration {
+
+	sub, err := fs.Sub(bundled, dir)
+	if err != nil {
+		panic(err)
+	}
+	ms, err := Load(sub)
+	if err != nil {
+		panic(err)
+	}
+	return ms
+}
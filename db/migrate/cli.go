@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/zicare/go-rpg/msg"
+)
+
+//Run exported
+//Runs the migration command in args on conn, writing what was done to w.
+//It's the entry point of cmd/rpg-migrate, and of commands applications
+//build to run Go migrations along with SQL ones:
+//
+//	up [n]      applies the first n pending migrations, all if n is missing
+//	down [n]    reverts the last n migrations applied, the last one if n is missing
+//	status      lists the migrations and when they were applied
+func Run(conn *sql.DB, ms []Migration, args []string, w io.Writer) error {
+
+	var (
+		cmd = "status"
+		n   int
+	)
+
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	if len(args) > 1 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 0 {
+			//Unknown migration command %s
+			return msg.Get("59").SetArgs(args[1]).M2E()
+		}
+	}
+
+	switch cmd {
+	case "up", "down":
+		run, verb := Up, "applied "
+		if cmd == "down" {
+			run, verb = Down, "reverted"
+		}
+		done, err := run(conn, ms, n)
+		for _, m := range done {
+			fmt.Fprintln(w, verb, name(m))
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "nothing to do")
+		}
+		return err
+	case "status":
+		states, err := Status(conn, ms)
+		for _, s := range states {
+			at := "pending"
+			if !s.Applied.IsZero() {
+				at = s.Applied.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%-19s  %s\n", at, name(s.Migration))
+		}
+		return err
+	}
+
+	//Unknown migration command %s
+	return msg.Get("59").SetArgs(cmd).M2E()
+}
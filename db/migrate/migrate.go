@@ -0,0 +1,390 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/msg"
+)
+
+/*
+ * Schema migrations
+ *
+ * Migrations are applied in version order, each one in a transaction
+ * recording its version in the schema_migrations table, so it's applied
+ * once. An advisory lock keeps concurrent runs, i.e. of several instances
+ * starting at once, from applying them twice. Engines committing DDL
+ * statements right away, mysql, can't roll back failed migrations.
+ *
+ * SQL migrations are files named version_name.up.sql and
+ * version_name.down.sql, versions being timestamps, i.e.
+ * 20190102150405_create_orders.up.sql. Go migrations are Migration values
+ * with Up and Down steps.
+ *
+ *	ms, err := migrate.Load(os.DirFS("migrations"))
+ *	if err != nil {
+ *		log.Fatal(err)
+ *	}
+ *	ms = append(ms, migrate.ACL()...)
+ *	if _, err := migrate.Up(db.Db(), ms, 0); err != nil {
+ *		log.Fatal(err)
+ *	}
+ */
+
+//Table exported
+//The table recording the migrations applied
+const Table = "schema_migrations"
+
+//Step exported
+//Runs a migration, or reverts it, within tx
+type Step func(tx *sql.Tx) error
+
+//Migration exported
+//A versioned schema change. Down is nil if it can't be reverted.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      Step
+	Down    Step
+}
+
+//State exported
+//A migration and when it was applied, zero if it wasn't
+type State struct {
+	Migration
+	Applied time.Time
+}
+
+//SQL exported
+//Returns the step executing the statements in q at once, mysql
+//requires multiStatements=true in the dsn for more than one
+func SQL(q string) Step {
+
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(q)
+		return err
+	}
+}
+
+//version_name.up.sql
+var file = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+//Load exported
+//Returns the SQL migrations in the root of fsys, other files are ignored
+func Load(fsys fs.FS) ([]Migration, error) {
+
+	var (
+		ms      []Migration
+		byVer   = make(map[int64]int)
+		entries []fs.DirEntry
+		err     error
+	)
+
+	if entries, err = fs.ReadDir(fsys, "."); err != nil {
+		//Server error: %s
+		return nil, msg.Get("25").SetArgs(err.Error()).M2E()
+	}
+
+	for _, e := range entries {
+		f := file.FindStringSubmatch(e.Name())
+		if e.IsDir() || f == nil {
+			continue
+		}
+
+		v, err := strconv.ParseInt(f[1], 10, 64)
+		if err != nil {
+			//Invalid migration file %s
+			return nil, msg.Get("58").SetArgs(e.Name()).M2E()
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			//Server error: %s
+			return nil, msg.Get("25").SetArgs(err.Error()).M2E()
+		}
+
+		i, ok := byVer[v]
+		if !ok {
+			i, byVer[v] = len(ms), len(ms)
+			ms = append(ms, Migration{Version: v, Name: f[2]})
+		} else if ms[i].Name != f[2] {
+			//Duplicate migration version %s
+			return nil, msg.Get("54").SetArgs(f[1]).M2E()
+		}
+		if f[3] == "up" {
+			ms[i].Up = SQL(string(b))
+		} else {
+			ms[i].Down = SQL(string(b))
+		}
+	}
+
+	for _, m := range ms {
+		if m.Up == nil {
+			//Invalid migration file %s
+			return nil, msg.Get("58").SetArgs(fmt.Sprintf("%d_%s.down.sql", m.Version, m.Name)).M2E()
+		}
+	}
+	return ms, nil
+}
+
+//Up exported
+//Applies the first n migrations pending, all of them if n is 0,
+//returning the ones applied
+func Up(conn *sql.DB, ms []Migration, n int) (done []Migration, err error) {
+
+	err = locked(conn, ms, func(ctx context.Context, c *sql.Conn, applied map[int64]time.Time) error {
+		for _, m := range sorted(ms) {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			} else if n > 0 && len(done) == n {
+				break
+			} else if err := apply(ctx, c, m, true); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return
+}
+
+//Down exported
+//Reverts the last n migrations applied, the last one if n is 0,
+//returning the ones reverted
+func Down(conn *sql.DB, ms []Migration, n int) (done []Migration, err error) {
+
+	if n <= 0 {
+		n = 1
+	}
+
+	err = locked(conn, ms, func(ctx context.Context, c *sql.Conn, applied map[int64]time.Time) error {
+
+		var (
+			byVer    = make(map[int64]Migration)
+			versions []int64
+		)
+
+		for _, m := range ms {
+			byVer[m.Version] = m
+		}
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] > versions[j]
+		})
+
+		for _, v := range versions {
+			m, ok := byVer[v]
+			if len(done) == n {
+				break
+			} else if !ok {
+				//Unknown migration %s
+				return msg.Get("57").SetArgs(v).M2E()
+			} else if m.Down == nil {
+				//Migration %s can't be reverted
+				return msg.Get("56").SetArgs(name(m)).M2E()
+			} else if err := apply(ctx, c, m, false); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return
+}
+
+//Status exported
+//Returns the state of every migration, in version order. It only reads,
+//taking no lock, none is applied if the migrations table is missing.
+func Status(conn *sql.DB, ms []Migration) (states []State, err error) {
+
+	var (
+		ctx     = context.Background()
+		applied = make(map[int64]time.Time)
+	)
+
+	if err = unique(ms); err != nil {
+		return nil, err
+	}
+
+	cols, err := db.Describe(ctx, conn, Table)
+	if err != nil {
+		//Server error: %s
+		return nil, msg.Get("25").SetArgs(err.Error()).M2E()
+	} else if len(cols) > 0 {
+		c, err := conn.Conn(ctx)
+		if err != nil {
+			//Server error: %s
+			return nil, msg.Get("25").SetArgs(err.Error()).M2E()
+		}
+		defer c.Close()
+		if applied, err = versions(ctx, c); err != nil {
+			//Server error: %s
+			return nil, msg.Get("25").SetArgs(err.Error()).M2E()
+		}
+	}
+
+	for _, m := range sorted(ms) {
+		states = append(states, State{Migration: m, Applied: applied[m.Version]})
+	}
+	return states, nil
+}
+
+//locked runs f holding the migrations lock on a connection of its
+//own, with the versions applied so far, once ms are checked and the
+//migrations table created if missing
+func locked(conn *sql.DB, ms []Migration, f func(context.Context, *sql.Conn, map[int64]time.Time) error) error {
+
+	ctx := context.Background()
+
+	if err := unique(ms); err != nil {
+		return err
+	}
+
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		//Server error: %s
+		return msg.Get("25").SetArgs(err.Error()).M2E()
+	}
+	defer c.Close()
+
+	unlock, err := db.Lock(ctx, c, Table)
+	if err != nil {
+		//Server error: %s
+		return msg.Get("25").SetArgs(err.Error()).M2E()
+	}
+	defer unlock()
+
+	if err := create(ctx, c); err != nil {
+		//Server error: %s
+		return msg.Get("25").SetArgs(err.Error()).M2E()
+	}
+	applied, err := versions(ctx, c)
+	if err != nil {
+		//Server error: %s
+		return msg.Get("25").SetArgs(err.Error()).M2E()
+	}
+	return f(ctx, c, applied)
+}
+
+//unique returns an error if two of ms share a version
+func unique(ms []Migration) error {
+
+	seen := make(map[int64]bool)
+	for _, m := range ms {
+		if seen[m.Version] {
+			//Duplicate migration version %s
+			return msg.Get("54").SetArgs(m.Version).M2E()
+		}
+		seen[m.Version] = true
+	}
+	return nil
+}
+
+//create creates the migrations table if missing
+func create(ctx context.Context, c *sql.Conn) error {
+
+	_, err := c.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+Table+` (
+		version    BIGINT       NOT NULL PRIMARY KEY,
+		name       VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP    NOT NULL
+	)`)
+	return err
+}
+
+//versions returns when every migration was applied by version
+func versions(ctx context.Context, c *sql.Conn) (map[int64]time.Time, error) {
+
+	applied := make(map[int64]time.Time)
+
+	sb := db.Flavor().NewSelectBuilder()
+	sb.Select("version", "applied_at")
+	sb.From(Table)
+
+	q, args := sb.Build()
+	rows, err := c.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			v  int64
+			at time.Time
+		)
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	return applied, rows.Err()
+}
+
+//apply runs the Up or Down step of m in a transaction,
+//recording or removing its version
+func apply(ctx context.Context, c *sql.Conn, m Migration, up bool) error {
+
+	var (
+		q    string
+		args []interface{}
+		step = m.Down
+	)
+
+	if up {
+		step = m.Up
+		ib := db.Flavor().NewInsertBuilder()
+		ib.InsertInto(Table)
+		ib.Cols("version", "name", "applied_at")
+		ib.Values(m.Version, m.Name, time.Now().UTC())
+		q, args = ib.Build()
+	} else {
+		dlb := db.Flavor().NewDeleteBuilder()
+		dlb.DeleteFrom(Table)
+		dlb.Where(dlb.Equal("version", m.Version))
+		q, args = dlb.Build()
+	}
+
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		//Server error: %s
+		return msg.Get("25").SetArgs(err.Error()).M2E()
+	}
+
+	if err = step(tx); err == nil {
+		_, err = tx.ExecContext(ctx, q, args...)
+	}
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+
+	if err != nil {
+		//Migration %s failed
+		return msg.Get("55").SetArgs(name(m) + ": " + err.Error()).M2E()
+	}
+	return nil
+}
+
+//sorted returns ms in version order
+func sorted(ms []Migration) []Migration {
+
+	out := append([]Migration{}, ms...)
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Version < out[j].Version
+	})
+	return out
+}
+
+//name returns the file name of m without the suffix
+func name(m Migration) string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
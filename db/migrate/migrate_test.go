@@ -0,0 +1,161 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/zicare/go-rpg/config"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/msg"
+)
+
+func TestMain(m *testing.M) {
+
+	dir, err := os.MkdirTemp("", "go-rpg")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Mkdir(filepath.Join(dir, "config"), 0755)
+	os.WriteFile(filepath.Join(dir, "config", "test.json"), []byte(`{
+		"db": {"driver": "sqlite3", "name": "`+filepath.Join(dir, "test.db")+`"}
+	}`), 0644)
+
+	if err := config.Init("test", dir); err != nil {
+		panic(err)
+	} else if err := msg.Init(nil); err != nil {
+		panic(err)
+	} else if err := db.Init(); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var files = fstest.MapFS{
+	"20190102000000_b.up.sql":   {Data: []byte("CREATE TABLE b (id INTEGER)")},
+	"20190102000000_b.down.sql": {Data: []byte("DROP TABLE b")},
+	"20190101000000_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER)")},
+	"20190101000000_a.down.sql": {Data: []byte("DROP TABLE a")},
+	"20190103000000_c.up.sql":   {Data: []byte("CREATE TABLE c (id INTEGER)")},
+	"README.md":                 {Data: []byte("ignored")},
+}
+
+func TestLoad(t *testing.T) {
+
+	ms, err := Load(files)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ms) != 3 {
+		t.Fatalf("got %d migrations, want 3", len(ms))
+	}
+	for i, want := range []string{"20190101000000_a", "20190102000000_b", "20190103000000_c"} {
+		if got := name(sorted(ms)[i]); got != want {
+			t.Errorf("%d: got %s, want %s", i, got, want)
+		}
+	}
+	if ms[0].Down == nil || ms[2].Down != nil {
+		t.Error("down steps don't match the files")
+	}
+
+	//down without up
+	if _, err := Load(fstest.MapFS{"1_a.down.sql": {}}); err == nil {
+		t.Error("got no error, want invalid migration file")
+	}
+}
+
+func TestUpDown(t *testing.T) {
+
+	var (
+		conn   = db.Db()
+		ms, _  = Load(files)
+		status = func() (applied []string) {
+			t.Helper()
+			states, err := Status(conn, ms)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range states {
+				if !s.Applied.IsZero() {
+					applied = append(applied, s.Name)
+				}
+			}
+			return
+		}
+	)
+
+	//read only, the migrations table isn't created
+	if applied := status(); len(applied) != 0 {
+		t.Errorf("applied %v, want none", applied)
+	} else if cols, _ := db.Describe(context.Background(), conn, Table); len(cols) != 0 {
+		t.Error("Status created the migrations table")
+	}
+
+	if done, err := Up(conn, ms, 2); err != nil {
+		t.Fatal(err)
+	} else if len(done) != 2 || done[0].Name != "a" || done[1].Name != "b" {
+		t.Errorf("Up applied %v, want a and b", done)
+	}
+	if done, err := Up(conn, ms, 0); err != nil {
+		t.Fatal(err)
+	} else if len(done) != 1 || done[0].Name != "c" {
+		t.Errorf("Up applied %v, want c", done)
+	}
+	if applied := status(); len(applied) != 3 {
+		t.Errorf("applied %v, want a, b and c", applied)
+	}
+
+	//c can't be reverted
+	if _, err := Down(conn, ms, 1); err == nil {
+		t.Error("got no error, want c can't be reverted")
+	}
+
+	ms[2].Down = SQL("DROP TABLE c")
+	if done, err := Down(conn, ms, 2); err != nil {
+		t.Fatal(err)
+	} else if len(done) != 2 || done[0].Name != "c" || done[1].Name != "b" {
+		t.Errorf("Down reverted %v, want c and b", done)
+	}
+	if applied := status(); len(applied) != 1 || applied[0] != "a" {
+		t.Errorf("applied %v, want a", applied)
+	}
+}
+
+func TestLock(t *testing.T) {
+
+	ctx := context.Background()
+
+	c1, _ := db.Db().Conn(ctx)
+	defer c1.Close()
+	c2, _ := db.Db().Conn(ctx)
+	defer c2.Close()
+
+	unlock, err := db.Lock(ctx, c1, Table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//held, a second run waits
+	wait, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	if u, err := db.Lock(wait, c2, Table); err == nil {
+		u()
+		t.Fatal("got the lock twice")
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := db.Lock(ctx, c2, Table); err != nil {
+		t.Fatalf("lock released: %v", err)
+	} else {
+		u()
+	}
+}
@@ -0,0 +1,8 @@
+CREATE TABLE acl (
+	role_id     BIGINT       NOT NULL,
+	route       VARCHAR(255) NOT NULL,
+	method      VARCHAR(10)  NOT NULL,
+	access_from TIMESTAMP    NOT NULL,
+	access_to   TIMESTAMP    NOT NULL,
+	PRIMARY KEY (role_id, route, method)
+);
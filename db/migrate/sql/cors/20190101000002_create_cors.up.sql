@@ -0,0 +1,5 @@
+CREATE TABLE cors (
+	app_key    VARCHAR(255) NOT NULL,
+	app_origin VARCHAR(255) NOT NULL,
+	PRIMARY KEY (app_key)
+);
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+//Parent exported
+//The parent row of a nested route, i.e. /customers/:id/orders, kept
+//in the gin context as "Parent". Rows of Table are those whose Col
+//holds Val, the parent key.
+type Parent struct {
+	Table string
+	Col   string
+	Val   string
+}
+
+//ParentOf exported
+//Returns the parent set for m by a nested route, if any
+func ParentOf(c *gin.Context, m Model) (Parent, bool) {
+
+	if c == nil {
+		return Parent{}, false
+	} else if p, ok := c.Get("Parent"); !ok {
+		return Parent{}, false
+	} else if p, ok := p.(Parent); ok && p.Table == m.Table() {
+		return p, true
+	}
+	return Parent{}, false
+}
+
+//under returns the where expression keeping the rows of m
+//under the parent of a nested route, empty out of them
+func under(c *gin.Context, m Model, cb *sqlbuilder.Cond, table string) string {
+
+	if p, ok := ParentOf(c, m); ok {
+		return cb.Equal(fmt.Sprintf("%s.%s", table, p.Col), p.Val)
+	}
+	return ""
+}
+
+//adopt sets the foreign key of m to the parent of a nested route
+func adopt(c *gin.Context, m Model) error {
+
+	if p, ok := ParentOf(c, m); ok {
+		return set(m, p.Col, p.Val)
+	}
+	return nil
+}
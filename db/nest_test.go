@@ -0,0 +1,71 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/lib"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//note is a child of item
+type note struct {
+	ID     *int64  `db:"id"      json:"id"      primary:"1" serial:"1"`
+	ItemID *int64  `db:"item_id" json:"item_id"`
+	Body   *string `db:"body"    json:"body"`
+}
+
+func (*note) New() Model {
+	return new(note)
+}
+
+func (*note) Table() string {
+	return "notes"
+}
+
+func (*note) View() string {
+	return "notes"
+}
+
+func (n *note) Val() interface{} {
+	return *n
+}
+
+func (n *note) Xfrm(c *gin.Context) Model {
+	return n
+}
+
+func (n *note) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*note) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*note) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return ErrDefaultDelete
+}
+
+func (*note) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+func TestNestedUpdateKeepsParent(t *testing.T) {
+
+	items(t, "a", "b")
+	schema(t,
+		"DROP TABLE IF EXISTS notes",
+		"CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, item_id INTEGER NOT NULL, body TEXT)",
+		"INSERT INTO notes (item_id, body) VALUES (1, 'x')",
+	)
+
+	//PUT /items/1/notes/1 moving the note to item 2
+	c := send("PUT", "/items/1/notes/1", `{"item_id": 2, "body": "y"}`, "Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	c.Set("Parent", Parent{Table: "notes", Col: "item_id", Val: "1"})
+
+	m := new(note)
+	if err := Update(c, m); err != nil {
+		t.Fatal(err)
+	} else if *m.Body != "y" || *m.ItemID != 1 {
+		t.Errorf("got %s under item %d, want y under item 1", *m.Body, *m.ItemID)
+	}
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/msg"
+)
+
+//Patch exported
+//Applies a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902),
+//told by the request content type, to the row identified by the id param.
+//The patched document is bound to m, then Model.Bind runs with it as
+//the request body. Unlike Update, members set to null or removed by the
+//patch set their columns to NULL.
+func Patch(c *gin.Context, m Model) error {
+
+	var (
+		apply func(doc []byte) ([]byte, error)
+		ct    = c.ContentType()
+	)
+
+	id, err := ParamIDs(c, m)
+	if err != nil {
+		//composite key misuse
+		return err
+	}
+
+	match, err := IfMatch(c, m)
+	if err != nil {
+		return err
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		//Server error: %s
+		return msg.Get("25").SetArgs(err.Error()).M2E()
+	}
+
+	switch ct {
+	case "application/merge-patch+json":
+		apply = func(doc []byte) ([]byte, error) {
+			return jsonpatch.MergePatch(doc, patch)
+		}
+	case "application/json-patch+json":
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			e := new(ParamError)
+			e.Copy(msg.Get("34").SetArgs(err.Error())) //Invalid patch: %s
+			return e
+		}
+		apply = p.Apply
+	default:
+		e := new(MediaTypeError)
+		e.Copy(msg.Get("33").SetArgs(ct)) //Unsupported media type %s
+		return e
+	}
+
+	return WithTx(c, func() error {
+
+		if err := findForUpdate(c, m, id, true); err != nil {
+			return err
+		}
+
+		doc, _ := json.Marshal(m.Val())
+		patched, err := apply(doc)
+		if err != nil {
+			e := new(ParamError)
+			e.Copy(msg.Get("34").SetArgs(err.Error())) //Invalid patch: %s
+			return e
+		}
+
+		cols := members(m, doc, patched)
+
+		//bind the patched document from scratch, as bulk items are
+		reflect.Indirect(reflect.ValueOf(m)).Set(reflect.Indirect(reflect.ValueOf(m.New())))
+		if err := json.Unmarshal(patched, m); err != nil {
+			return err
+		}
+		rebind(c, patched)
+		if err := bind(c, m, id); err != nil {
+			//payload problem
+			return err
+		}
+
+		return update(c, m, id, cols, match)
+	})
+}
+
+//members returns the columns of m whose json members the patch
+//changed, added or removed, the ones removed will be set to NULL
+func members(m Model, doc []byte, patched []byte) (cols []string) {
+
+	var (
+		before, after map[string]interface{}
+		js            = TAG(m, "json")
+	)
+
+	//nil would write them all
+	cols = []string{}
+
+	json.Unmarshal(doc, &before)
+	json.Unmarshal(patched, &after)
+
+	for f, k := range TAG(m, "db") {
+		j, ok := js[f]
+		if !ok {
+			continue
+		}
+		j = strings.Split(j, ",")[0]
+		if !reflect.DeepEqual(before[j], after[j]) {
+			cols = append(cols, k)
+		}
+	}
+	return
+}
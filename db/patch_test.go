@@ -0,0 +1,45 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMembers(t *testing.T) {
+
+	doc := []byte(`{"id": 1, "name": "a", "version": 1}`)
+	for patched, want := range map[string][]string{
+		`{"id": 1, "name": "b", "version": 1}`: {"name"},
+		`{"id": 1, "version": 1}`:              {"name"},
+		`{"id": 1, "name": "a", "version": 1}`: {},
+	} {
+		if got := members(new(item), doc, []byte(patched)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", patched, got, want)
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+
+	items(t, "a")
+
+	c := send("PATCH", "/items/1", `{"name": "b"}`, "Content-Type", "application/merge-patch+json", "If-Match", `"1"`)
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	m := new(item)
+	if err := Patch(c, m); err != nil {
+		t.Fatal(err)
+	} else if *m.Name != "b" || *m.Version != 2 {
+		t.Errorf("got %s version %d, want b version 2", *m.Name, *m.Version)
+	}
+
+	//nothing changes but the version
+	c = send("PATCH", "/items/1", `{}`, "Content-Type", "application/merge-patch+json", "If-Match", `"2"`)
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	if err := Patch(c, m); err != nil {
+		t.Fatal(err)
+	} else if *m.Name != "b" || *m.Version != 3 {
+		t.Errorf("got %s version %d, want b version 3", *m.Name, *m.Version)
+	}
+}
@@ -27,46 +27,35 @@ func prefix(s []string, p string) []string {
 	return ps
 }
 
-//FetchAll exported
-func FetchAll(c *gin.Context, m Model) (ResultSetMeta, []interface{}, error) {
-
-	var (
-		pxc     []string
-		opt     = params(c, m)
-		meta    = ResultSetMeta{Range: "*/*", Checksum: "*"}
-		total   string
-		results []interface{}
-		table   = m.View()
-		ms      = sqlbuilder.NewStruct(m).For(sqlbuilder.PostgreSQL)
-		sb      = ms.SelectFrom(table)
-
-		//where
-		fnFst = func(v string) string { p := strings.Split(v, ","); return p[0] }
-		cond  = map[string]func(string, string) string{
-			"eq": func(k string, v string) string {
-				p := strings.Split(v, ",")
-				if len(p) > 1 {
-					return sb.In(k, sqlbuilder.Flatten(p)...)
-				}
-				return sb.Equal(k, v)
-			},
-			"gt":   func(k string, v string) string { return sb.GreaterThan(k, fnFst(v)) },
-			"gteq": func(k string, v string) string { return sb.GreaterEqualThan(k, fnFst(v)) },
-			"st":   func(k string, v string) string { return sb.LessThan(k, fnFst(v)) },
-			"steq": func(k string, v string) string { return sb.LessEqualThan(k, fnFst(v)) },
-		}
-	)
+//narrow sets the where clause of sb, the scope of m, rows not soft
+//deleted and under the parent of nested routes, the filters in opt and
+//the full-text search match, returning its rank and headline expressions
+func narrow(c *gin.Context, m Model, sb *sqlbuilder.SelectBuilder, opt SelectOpt, table string) (rank string, headline string) {
 
 	//set where scope
 	m.Scope(sb, c)
 
+	//set where not soft deleted
+	if e := live(c, m, &sb.Cond, table); e != "" {
+		sb.Where(e)
+	}
+
+	//set where under the parent
+	if e := under(c, m, &sb.Cond, table); e != "" {
+		sb.Where(e)
+	}
+
 	//set where
-	for i, j := range cond {
-		op, ok := opt.Filter[i]
-		if ok {
-			for _, v := range op {
-				sb.Where(j(fmt.Sprintf("%s.%s", table, v.A.(string)), v.B.(string)))
-			}
+	for _, op := range operators {
+		for _, v := range opt.Filter[op] {
+			sb.Where(filter(&sb.Cond, op, fmt.Sprintf("%s.%s", table, v.A.(string)), v.B.(string)))
+		}
+	}
+
+	//set where groups
+	for _, g := range opt.Group {
+		if e := g.where(&sb.Cond, table); e != "" {
+			sb.Where(e)
 		}
 	}
 
@@ -80,25 +69,132 @@ func FetchAll(c *gin.Context, m Model) (ResultSetMeta, []interface{}, error) {
 		sb.Where(sb.IsNotNull(j))
 	}
 
+	//set where full-text search
+	match, rank, headline := search(m, &sb.Cond, opt, table)
+	if match != "" {
+		sb.Where(match)
+	}
+	return rank, headline
+}
+
+//FetchAll exported
+func FetchAll(c *gin.Context, m Model) (ResultSetMeta, []interface{}, error) {
+
+	var (
+		opt     = params(c, m)
+		results []interface{}
+	)
+
+	meta, total, err := fetch(c, m, opt, 0, func(_ ResultSetMeta, rows []interface{}) error {
+		results = append(results, rows...)
+		return nil
+	})
+	if err != nil || total == "0" {
+		return meta, results, err
+	}
+
+	//meta
+	from := strconv.Itoa(opt.Offset)
+	to := strconv.Itoa(lib.Max(opt.Offset, len(results)-opt.Offset-1))
+	meta.Range = fmt.Sprintf("%s-%s/%s", from, to, total)
+	meta.ETag = Digest([]interface{}{meta.Range, meta.Next, results})
+	if opt.Checksum == 1 {
+		bytes, _ := json.Marshal(results)
+		checksum := crc32.ChecksumIEEE([]byte(bytes))
+		meta.Checksum = strconv.FormatUint(uint64(checksum), 16)
+	}
+
+	return meta, results, nil
+}
+
+//fetch runs the FetchAll query handing the rows to emit in chunks of
+//the given size, all at once if 0. Relations are loaded every chunk.
+//The meta emit gets holds the expected range, the one returned holds
+//the cursor to the next page too. Returns the total count of rows.
+func fetch(c *gin.Context, m Model, opt SelectOpt, chunk int, emit func(ResultSetMeta, []interface{}) error) (ResultSetMeta, string, error) {
+
+	var (
+		pxc    []string
+		keys   []string
+		desc   []bool
+		last   []interface{}
+		hidden []string
+		n      int
+		meta   = ResultSetMeta{Range: "*/*", Checksum: "*"}
+		total  string
+		table  = m.View()
+		ms     = Struct(m)
+		sb     = ms.SelectFrom(table)
+	)
+
+	ctx, cancel := Context(c, m)
+	defer cancel()
+
+	//set where
+	rank, headline := narrow(c, m, sb, opt, table)
+
 	//get total count
 	sb.Select(sb.As("COUNT(*)", "t"))
 	sql, args := sb.Build()
 	//fmt.Println(sql, args)
-	err := db.QueryRow(sql, args...).Scan(&total)
+	err := Conn(c).QueryRowContext(ctx, sql, args...).Scan(&total)
 	if err != nil {
 		//Server error: %s
-		return meta, results, msg.Get("25").SetArgs(err.Error()).M2E()
+		return meta, total, fail(c, err)
 	}
 
 	//total = 0 ? no need to continue
 	if total == "0" {
-		return meta, results, nil
+		return meta, total, nil
+	}
+
+	//set keyset, the cursor replaces the offset
+	fields, _ := Fields(m)
+	if opt.Cursor {
+		keys, desc = keyset(&opt.Order, fields.Primary)
+		hidden = slice.Diff(keys, opt.Column)
+		opt.Column = append(opt.Column, hidden...)
+		opt.Offset = 0
+		if opt.After != "" {
+			after, err := decodeCursor(opt.After, len(keys))
+			if err != nil {
+				return meta, total, err //*ParamError
+			}
+			sb.Where(seek(&sb.Cond, table, keys, desc, after))
+		}
+	}
+
+	//expected range
+	if t, _ := strconv.Atoi(total); t > 0 {
+		meta.Range = fmt.Sprintf("%d-%d/%s", opt.Offset, lib.Min(opt.Offset+opt.Limit, t)-1, total)
+	}
+
+	//set the columns relations are loaded by
+	if len(opt.Embed) > 0 {
+		rels := relations(m)
+		for _, name := range opt.Embed {
+			if k := rels[name].local; !slice.Contains(opt.Column, k) {
+				opt.Column = append(opt.Column, k)
+				hidden = append(hidden, k)
+			}
+		}
 	}
 
 	//set columns, order by, limit and offset
 	pxc = prefix(opt.Column, table)
+	if opt.Headline && headline != "" {
+		pxc = append(pxc, sb.As(headline, "headline"))
+	} else {
+		headline = ""
+	}
 	sb.Select(pxc...)
 	pxc = prefix(opt.Order, table)
+	if opt.Cursor {
+		pxc = orderBy(table, keys, desc, fields.Primary)
+	} else if rank != "" && len(opt.Order) == 0 {
+		//best matches first
+		pxc = append([]string{rank + " DESC"}, pxc...)
+	}
 	sb.OrderBy(pxc...)
 	sb.Limit(opt.Limit)
 	sb.Offset(opt.Offset)
@@ -106,35 +202,86 @@ func FetchAll(c *gin.Context, m Model) (ResultSetMeta, []interface{}, error) {
 	//buils sql and execute it
 	sql, args = sb.Build()
 	//fmt.Println(sql, args) /////////////////////////////////////////////////////////////////////////////
-	rows, err := db.Query(sql, args...)
+	rows, err := Conn(c).QueryContext(ctx, sql, args...)
+	if err != nil {
+		//Server error: %s
+		return meta, total, fail(c, err)
+	}
 	defer rows.Close()
 
 	//scan rows
+	var (
+		found []Model
+		heads []*string
+	)
+
+	//flush loads the relations of the rows found and emits them
+	flush := func() error {
+		if len(found) == 0 {
+			return nil
+		} else if err := embed(c, found, opt.Embed); err != nil {
+			return err
+		}
+		out := make([]interface{}, 0, len(found))
+		for i, row := range found {
+			//columns selected for the cursor or the relations only
+			unset(row, hidden)
+			if headline != "" {
+				out = append(out, highlight(row.Xfrm(c).Val(), heads[i]))
+			} else {
+				out = append(out, row.Xfrm(c).Val())
+			}
+		}
+		n += len(found)
+		found, heads = found[:0], heads[:0]
+		return emit(meta, out)
+	}
+
 	for rows.Next() {
-		err := rows.Scan(ms.AddrWithCols(opt.Column, &m)...)
+		var (
+			row  = m.New()
+			head *string
+			addr = ms.AddrWithCols(opt.Column, &row)
+		)
+		if headline != "" {
+			addr = append(addr, &head)
+		}
+		err := rows.Scan(addr...)
 		if err != nil {
 			//Server error: %s
-			return meta, results, msg.Get("25").SetArgs(err.Error()).M2E()
+			return meta, total, fail(c, err)
+		}
+		if opt.Cursor {
+			_, val := Fields(row)
+			last = last[:0]
+			for _, k := range keys {
+				last = append(last, val[k])
+			}
+		}
+		found = append(found, row)
+		heads = append(heads, head)
+		if chunk > 0 && len(found) == chunk {
+			if err := flush(); err != nil {
+				return meta, total, err
+			}
 		}
-		results = append(results, m.Xfrm(c).Val())
 	}
 	err = rows.Err()
 	if err != nil {
 		//Server error: %s
-		return meta, results, msg.Get("25").SetArgs(err.Error()).M2E()
+		return meta, total, fail(c, err)
 	}
+	rows.Close()
 
-	//meta
-	from := strconv.Itoa(opt.Offset)
-	to := strconv.Itoa(lib.Max(opt.Offset, len(results)-opt.Offset-1))
-	meta.Range = fmt.Sprintf("%s-%s/%s", from, to, total)
-	if opt.Checksum == 1 {
-		bytes, _ := json.Marshal(results)
-		checksum := crc32.ChecksumIEEE([]byte(bytes))
-		meta.Checksum = strconv.FormatUint(uint64(checksum), 16)
+	//last chunk
+	if err := flush(); err != nil {
+		return meta, total, err
 	}
 
-	return meta, results, nil
+	if opt.Cursor && n == opt.Limit {
+		meta.Next = encodeCursor(last)
+	}
+	return meta, total, nil
 }
 
 //Find exported
@@ -144,6 +291,8 @@ func Find(c *gin.Context, m Model) error {
 		return err //*ParamError
 	} else if err := find(c, m, id, true); err != nil {
 		return err
+	} else if err := embed(c, []Model{m}, embeds(c, m)); err != nil {
+		return err
 	}
 	return nil
 }
@@ -153,38 +302,77 @@ func Insert(c *gin.Context, m Model) error {
 
 	if err := c.ShouldBind(m); err != nil {
 		return err
-	} else if err := m.Bind(c, []lib.Pair{}); err != nil {
+	} else if err := bind(c, m, []lib.Pair{}); err != nil {
+		return err
+	}
+
+	return create(c, m)
+}
+
+//create writes the bound model and reads it back
+func create(c *gin.Context, m Model) error {
+
+	//nested routes set the parent key
+	if err := adopt(c, m); err != nil {
 		return err
 	}
 
 	var (
 		table       = m.Table()
 		fields, val = Fields(m)
-		ms          = sqlbuilder.NewStruct(m).For(sqlbuilder.PostgreSQL)
-		ib          = sqlbuilder.PostgreSQL.NewInsertBuilder()
+		ms          = Struct(m)
+		ib          = Flavor().NewInsertBuilder()
+		serial      []string
+		cols        []string
+		v           []interface{}
 	)
 
-	var v []interface{}
-	for _, w := range fields.Ordered {
+	//serial primary keys are left to the db, and versions to their default
+	for _, w := range fields.Writable {
 		if slice.Contains(fields.Primary, w) && slice.Contains(fields.Serial, w) {
-			v = append(v, sqlbuilder.Raw("DEFAULT"))
-		} else if !slice.Contains(fields.View, w) {
+			serial = append(serial, w)
+		} else if w == fields.Version && isNull(val[w]) {
+			continue
+		} else {
+			cols = append(cols, w)
 			v = append(v, val[w])
 		}
 	}
 
 	ib.InsertInto(table)
+	ib.Cols(cols...)
 	ib.Values(v...)
 
-	sql, args := ib.Build()
-	//fmt.Println(sql, args)
-	if err := db.QueryRow(sql+" RETURNING *", args...).
-		Scan(ms.AddrWithCols(fields.Writable, &m)...); err != nil {
+	ctx, cancel := Context(c, m)
+	defer cancel()
+
+	q, args := ib.Build()
+	//fmt.Println(q, args)
+	if dialect.Returning() {
+		err := Conn(c).QueryRowContext(ctx, q+" RETURNING "+strings.Join(fields.Primary, ", "), args...).
+			Scan(ms.AddrWithCols(fields.Primary, &m)...)
+		if err != nil {
+			//Server error: %s
+			return failWrite(c, m, err)
+		}
+	} else if res, err := Conn(c).ExecContext(ctx, q, args...); err != nil {
 		//Server error: %s
-		return msg.Get("25").SetArgs(err.Error()).M2E()
+		return failWrite(c, m, err)
+	} else if len(serial) == 1 {
+		id, err := res.LastInsertId()
+		if err != nil {
+			//Server error: %s
+			return fail(c, err)
+		} else if err := assign(ms.AddrWithCols(serial, &m)[0], strconv.FormatInt(id, 10)); err != nil {
+			return err
+		}
 	}
 
-	return find(c, m, PID(m, fields.Primary), false)
+	pID, err := PrimaryKey(m)
+	if err != nil {
+		return err
+	}
+	return find(c, m, pID, false)
 }
 
 //Update exported
@@ -193,27 +381,53 @@ func Update(c *gin.Context, m Model) error {
 	var (
 		err   error
 		id    []lib.Pair
-		table = m.Table()
-		meta  Meta
-		val   map[string]interface{}
-		ub    = sqlbuilder.PostgreSQL.NewUpdateBuilder()
+		match []string
 	)
 
 	if id, err = ParamIDs(c, m); err != nil {
 		//composite key misuse
 		return err
+	} else if match, err = IfMatch(c, m); err != nil {
+		return err
 	} else if err := c.ShouldBind(m); err != nil {
 		return err
-	} else if err = m.Bind(c, id); err != nil {
+	} else if err = bind(c, m, id); err != nil {
 		//payload problem
 		return err
 	}
 
-	meta, val = Fields(m)
+	return update(c, m, id, nil, match)
+}
+
+//update sets the writable columns in cols, null values included,
+//or every writable non null column if cols is nil. Versioned rows
+//are only updated if they hold any of the versions in match.
+//Rows of nested routes stay under their parent.
+func update(c *gin.Context, m Model, id []lib.Pair, cols []string, match []string) error {
+
+	//nested routes keep the parent key
+	if err := adopt(c, m); err != nil {
+		return err
+	}
+
+	var (
+		table     = m.Table()
+		meta, val = Fields(m)
+		ub        = Flavor().NewUpdateBuilder()
+	)
+
 	ub.Update(table)
 
 	m.Scope(ub, c)
 
+	if e := live(c, m, &ub.Cond, table); e != "" {
+		ub.Where(e)
+	}
+
+	if e := under(c, m, &ub.Cond, table); e != "" {
+		ub.Where(e)
+	}
+
 	for _, p := range id {
 		ub.Where(ub.Equal(p.A.(string), p.B.(string)))
 		_, ok := val[p.A.(string)]
@@ -222,23 +436,39 @@ func Update(c *gin.Context, m Model) error {
 		}
 	}
 
+	if e, err := versioned(&ub.Cond, m, match); err != nil {
+		return err
+	} else if e != "" {
+		ub.Where(e)
+	}
+
 	var asg []string
 	for k, v := range val {
-		if !reflect.ValueOf(v).IsNil() && slice.Contains(meta.Writable, k) {
+		if !slice.Contains(meta.Writable, k) || k == meta.Version {
+			continue
+		} else if cols == nil && !reflect.ValueOf(v).IsNil() || slice.Contains(cols, k) {
 			asg = append(asg, ub.Assign(k, v))
 		}
 	}
+	if b := bump(ub, m); b != "" {
+		asg = append(asg, b)
+	} else if len(asg) == 0 {
+		//nothing to write, unversioned
+		return find(c, m, id, true)
+	}
 	ub.Set(asg...)
 
+	ctx, cancel := Context(c, m)
+	defer cancel()
+
 	sql, args := ub.Build()
 	//fmt.Println(sql, args)
-	if res, err := db.Exec(sql, args...); err != nil {
+	if res, err := Conn(c).ExecContext(ctx, sql, args...); err != nil {
 		//Server error: %s
-		return msg.Get("25").SetArgs(err.Error()).M2E()
+		return failWrite(c, m, err)
 	} else if rows, _ := res.RowsAffected(); rows == 0 {
-		e := new(NotFoundError)
-		e.Copy(msg.Get("18")) //Not found!
-		return e
+		//not found, out of scope or version mismatch
+		return missed(c, m, id, match)
 	}
 
 	return find(c, m, id, false)
@@ -251,9 +481,21 @@ func ByID(c *gin.Context, m Model, id []lib.Pair) error {
 
 func find(c *gin.Context, m Model, id []lib.Pair, scope bool) error {
 
+	return selectRow(c, m, id, scope, false)
+}
+
+//findForUpdate is find locking the row until the transaction ends,
+//for reads that are modified and written back
+func findForUpdate(c *gin.Context, m Model, id []lib.Pair, scope bool) error {
+
+	return selectRow(c, m, id, scope, dialect.ForUpdate())
+}
+
+func selectRow(c *gin.Context, m Model, id []lib.Pair, scope bool, lock bool) error {
+
 	var (
 		table = m.View()
-		ms    = sqlbuilder.NewStruct(m).For(sqlbuilder.PostgreSQL)
+		ms    = Struct(m)
 		sb    = ms.SelectFrom(table)
 	)
 
@@ -261,19 +503,49 @@ func find(c *gin.Context, m Model, id []lib.Pair, scope bool) error {
 		m.Scope(sb, c)
 	}
 
+	if e := live(c, m, &sb.Cond, table); e != "" {
+		sb.Where(e)
+	}
+
+	if e := under(c, m, &sb.Cond, table); e != "" {
+		sb.Where(e)
+	}
+
 	for _, p := range id {
 		sb.Where(sb.Equal(p.A.(string), p.B.(string)))
 	}
 
+	if lock {
+		sb.ForUpdate()
+	}
+
+	ctx, cancel := Context(c, m)
+	defer cancel()
+
 	q, args := sb.Build()
 	//log.Println(q, args)
-	if err := db.QueryRow(q, args...).Scan(ms.Addr(&m)...); err == sql.ErrNoRows {
+	if err := Conn(c).QueryRowContext(ctx, q, args...).Scan(ms.Addr(&m)...); err == sql.ErrNoRows {
 		e := new(NotFoundError)
 		e.Copy(msg.Get("18")) //Not found!
 		return e
 	} else if err != nil {
 		//Server error: %s
-		return msg.Get("25").SetArgs(err.Error()).M2E()
+		return fail(c, err)
 	}
 	return nil
 }
+
+//unset sets the fields of the columns given to their zero value
+func unset(m Model, cols []string) {
+
+	var (
+		fields, _ = Fields(m)
+		v         = reflect.Indirect(reflect.ValueOf(m))
+	)
+
+	for _, k := range cols {
+		if f := v.FieldByName(fields.Field[k]); f.IsValid() && f.CanSet() {
+			f.Set(reflect.Zero(f.Type()))
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package db
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/msg"
+)
+
+/*
+ * Model registry
+ *
+ * The metadata of every model type is computed from its struct tags once
+ * and kept by the registry, so requests don't walk the tags again. Models
+ * are registered when first used, Register does it upfront and reports
+ * the tag errors found, i.e. on init:
+ *
+ *	if err := db.Register(new(Person), new(Order)); err != nil {
+ *		log.Fatal(err)
+ *	}
+ */
+
+//model is the registry entry of a model type
+type model struct {
+	proto Model
+	meta  Meta
+	index []int
+	st    *sqlbuilder.Struct
+	once  sync.Once
+	rels  map[string]relation
+	err   error
+}
+
+var registry sync.Map //map[reflect.Type]*model
+
+//Register exported
+//Adds the models to the registry, returning the first tag error found
+func Register(models ...Model) error {
+
+	for _, m := range models {
+		if r := lookup(m); r.err != nil {
+			return r.err
+		}
+	}
+	return nil
+}
+
+//Struct exported
+//Returns the sql builder struct of m for the configured dialect
+func Struct(m Model) *sqlbuilder.Struct {
+	return lookup(m).st.For(Flavor())
+}
+
+//lookup returns the registry entry of m, adding it if missing
+func lookup(m Model) *model {
+
+	t := reflect.TypeOf(m.Val())
+	if r, ok := registry.Load(t); ok {
+		return r.(*model)
+	}
+
+	//tag errors are kept for Register to report
+	r, err := scan(m)
+	r.err = err
+	actual, _ := registry.LoadOrStore(t, r)
+	return actual.(*model)
+}
+
+//relations returns the relations of m by name, computed once
+func relations(m Model) map[string]relation {
+
+	r := lookup(m)
+	r.once.Do(func() {
+		r.rels = related(m)
+	})
+	return r.rels
+}
+
+//scan computes the metadata of m from its struct tags
+func scan(m Model) (*model, error) {
+
+	var (
+		t     = reflect.Indirect(reflect.ValueOf(m.Val())).Type()
+		r     = &model{proto: m.New(), st: sqlbuilder.NewStruct(m)}
+		meta  = &r.meta
+		first error
+	)
+
+	meta.JSON = make(map[string]string)
+	meta.Field = make(map[string]string)
+	meta.Tags = make(map[string]map[string]string)
+
+	invalid := func(tag string, f reflect.StructField) {
+		if first == nil {
+			first = msg.Get("48").SetArgs(tag, t.Name(), f.Name).M2E() //Invalid %s tag on %s.%s
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+
+		f := t.Field(i)
+		tags, err := parseTag(string(f.Tag))
+		if err != nil {
+			invalid("struct", f)
+		}
+		for name, v := range tags {
+			if meta.Tags[name] == nil {
+				meta.Tags[name] = make(map[string]string)
+			}
+			meta.Tags[name][f.Name] = v
+		}
+
+		k, ok := tags["db"]
+		if !ok || k == "-" {
+			//only columns may be flagged
+			for _, flag := range []string{"primary", "serial", "softdelete", "version", "updated", "search"} {
+				if _, ok := tags[flag]; ok {
+					invalid(flag, f)
+				}
+			}
+			continue
+		} else if _, dup := meta.Field[k]; dup || k == "" {
+			invalid("db", f)
+			continue
+		}
+
+		meta.Ordered = append(meta.Ordered, k)
+		meta.Field[k] = f.Name
+		r.index = append(r.index, i)
+
+		//json name, as encoding/json does
+		switch j := strings.Split(tags["json"], ",")[0]; {
+		case j == "-":
+		case j == "":
+			meta.JSON[k] = f.Name
+		default:
+			meta.JSON[k] = j
+		}
+
+		//flags are either 1 or 0
+		for _, flag := range []string{"primary", "serial", "softdelete", "version", "updated"} {
+			if v, ok := tags[flag]; ok && v != "1" && v != "0" {
+				invalid(flag, f)
+			}
+		}
+
+		//check for primary
+		if tags["primary"] == "1" {
+			meta.Primary = append(meta.Primary, k)
+		}
+		//check for serial
+		if tags["serial"] == "1" {
+			meta.Serial = append(meta.Serial, k)
+		}
+		//check for view or writable
+		if view, ok := tags["view"]; !ok {
+			meta.Writable = append(meta.Writable, k)
+		} else if view == "1" {
+			meta.View = append(meta.View, k)
+		}
+		//check for soft delete
+		if tags["softdelete"] == "1" {
+			if meta.SoftDelete != "" {
+				invalid("softdelete", f)
+			}
+			meta.SoftDelete = k
+		}
+		//check for version
+		if tags["version"] == "1" {
+			if meta.Version != "" || !versionable(f.Type) {
+				invalid("version", f)
+			}
+			meta.Version = k
+		}
+		//check for last update
+		if tags["updated"] == "1" {
+			if meta.Updated != "" {
+				invalid("updated", f)
+			}
+			meta.Updated = k
+		}
+		//check for full-text search
+		switch s, ok := tags["search"]; {
+		case !ok:
+		case s == "1":
+			meta.Search = append(meta.Search, k)
+		case s == "tsvector" && meta.Vector == "":
+			meta.Vector = k
+		default:
+			invalid("search", f)
+		}
+	}
+
+	//fk tags of columns name the column referenced, table.column,
+	//those of other fields name a column holding one
+	for f, v := range meta.Tags["fk"] {
+		sf, _ := t.FieldByName(f)
+		if k, ok := meta.Tags["db"][f]; ok && k != "-" {
+			if i := strings.LastIndex(v, "."); i < 1 || i == len(v)-1 {
+				invalid("fk", sf)
+			}
+		} else if col, ok := meta.Field[v]; !ok {
+			invalid("fk", sf)
+		} else if _, ok := meta.Tags["fk"][col]; !ok {
+			invalid("fk", sf)
+		}
+	}
+
+	//shared slices are full, appending to them copies
+	meta.Ordered = meta.Ordered[:len(meta.Ordered):len(meta.Ordered)]
+	meta.Primary = meta.Primary[:len(meta.Primary):len(meta.Primary)]
+	meta.Serial = meta.Serial[:len(meta.Serial):len(meta.Serial)]
+	meta.View = meta.View[:len(meta.View):len(meta.View)]
+	meta.Writable = meta.Writable[:len(meta.Writable):len(meta.Writable)]
+	meta.Search = meta.Search[:len(meta.Search):len(meta.Search)]
+
+	return r, first
+}
+
+//versionable tells if columns of type t may be versions,
+//integers or timestamps
+func versionable(t reflect.Type) bool {
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return t == reflect.TypeOf(time.Time{})
+}
+
+//parseTag returns the key:"value" pairs of a struct tag,
+//following the convention reflect.StructTag.Get relies on
+func parseTag(tag string) (map[string]string, error) {
+
+	out := make(map[string]string)
+
+	for tag != "" {
+		//skip leading space
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		if tag = tag[i:]; tag == "" {
+			break
+		}
+
+		//scan to colon
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return out, strconv.ErrSyntax
+		}
+		name := tag[:i]
+		tag = tag[i+1:]
+
+		//scan quoted string to find value
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return out, strconv.ErrSyntax
+		}
+		value, err := strconv.Unquote(tag[:i+1])
+		if err != nil {
+			return out, err
+		}
+		out[name] = value
+		tag = tag[i+1:]
+	}
+	return out, nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/zicare/go-rpg/lib"
+)
+
+func BenchmarkFields(b *testing.B) {
+
+	id := int64(1)
+	m := &item{ID: &id}
+	for i := 0; i < b.N; i++ {
+		Fields(m)
+	}
+}
+
+func BenchmarkPID(b *testing.B) {
+
+	id := int64(1)
+	m := &item{ID: &id}
+	for i := 0; i < b.N; i++ {
+		if pID := PID(m, nil); len(pID) != 1 {
+			b.Fatal(pID)
+		}
+	}
+}
+
+//the baselines reflect over the tags on every call, as Fields and
+//PID did before the registry cached them
+
+func BenchmarkFieldsUncached(b *testing.B) {
+
+	id := int64(1)
+	m := &item{ID: &id}
+	for i := 0; i < b.N; i++ {
+		if _, err := scan(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPIDUncached(b *testing.B) {
+
+	id := int64(1)
+	m := &item{ID: &id}
+	for i := 0; i < b.N; i++ {
+		var (
+			pID []lib.Pair
+			v   = reflect.Indirect(reflect.ValueOf(m.Val()))
+		)
+		for j := 0; j < v.NumField(); j++ {
+			if f := v.Type().Field(j); f.Tag.Get("primary") == "1" {
+				pID = append(pID, lib.Pair{A: f.Tag.Get("db"), B: fmt.Sprintf("%v", reflect.Indirect(v.Field(j)))})
+			}
+		}
+		if len(pID) != 1 {
+			b.Fatal(pID)
+		}
+	}
+}
+
+type bad struct {
+	item
+	Rank *int64 `db:"rank" version:"2"`
+}
+
+func (b *bad) Val() interface{} {
+	return b
+}
+
+func TestRegisterKeepsTagErrors(t *testing.T) {
+
+	//first use registers the model, Register still reports its tags
+	Fields(new(bad))
+	if err := Register(new(bad)); err == nil {
+		t.Error("Register(bad) = nil, want invalid version tag")
+	}
+	if err := Register(new(item)); err != nil {
+		t.Errorf("Register(item) = %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/config"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/msg"
+	"github.com/zicare/go-rpg/slice"
+)
+
+//Repo exported
+//Typed access to the rows of the model T, a pointer type, i.e.
+//
+//	people := db.Repo[*Person]{}
+//	list, meta, err := people.List(ctx, db.SelectOpt{Limit: 10})
+//
+//The context may be the *gin.Context of a request, then its scope,
+//transaction and headers apply as in FetchAll, Find and the like.
+//Any other context, i.e. from a background job, runs out of a request,
+//Model.Scope gets a *gin.Context holding nothing but that context.
+type Repo[T Model] struct{}
+
+//List exported
+//Returns the rows selected by opts as FetchAll does. Filters,
+//groups, order, limit, cursor, embed and search are honored.
+//All columns are selected if none is given, param.icpp rows
+//if no limit is. Unknown columns return ParamError.
+func (Repo[T]) List(ctx context.Context, opts SelectOpt) ([]T, ResultSetMeta, error) {
+
+	var (
+		c    = ginContext(ctx)
+		m    = newT[T]()
+		list []T
+	)
+
+	if err := known(fields(m), opts); err != nil {
+		return list, ResultSetMeta{Range: "*/*", Checksum: "*"}, err
+	}
+
+	if len(opts.Column) == 0 {
+		opts.Column = fields(m).Ordered
+	}
+	if opts.Limit <= 0 {
+		opts.Limit, _ = strconv.Atoi(config.Config().GetString("param.icpp"))
+	}
+
+	//there's no place for them in T
+	opts.Headline = false
+
+	meta, _, err := fetch(c, m, opts, 0, func(_ ResultSetMeta, rows []interface{}) error {
+		for _, v := range rows {
+			list = append(list, typed[T](v))
+		}
+		return nil
+	})
+	return list, meta, err
+}
+
+//Get exported
+//Returns the scoped row with the primary key values given,
+//in the order of the primary key columns
+func (Repo[T]) Get(ctx context.Context, id ...interface{}) (T, error) {
+
+	var (
+		c = ginContext(ctx)
+		m = newT[T]()
+	)
+
+	pIDs, err := pairs(m, id)
+	if err != nil {
+		return m, err
+	} else if err := find(c, m, pIDs, true); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+//Create exported
+//Inserts m as Insert does, but m isn't bound nor validated, it's up
+//to the caller. Returns m as read back.
+func (Repo[T]) Create(ctx context.Context, m T) (T, error) {
+
+	err := create(ginContext(ctx), m)
+	return m, err
+}
+
+//Update exported
+//Updates the non null writable columns of the scoped row keyed by m,
+//as Update does. Versioned rows must hold the version they had when read.
+//Returns m as read back.
+func (Repo[T]) Update(ctx context.Context, m T) (T, error) {
+
+	c := ginContext(ctx)
+	match(c, ctx, m)
+
+	if id, err := keyed(m, fields(m).Primary); err != nil {
+		return m, err
+	} else if versions, err := IfMatch(c, m); err != nil {
+		return m, err
+	} else {
+		return m, update(c, m, id, nil, versions)
+	}
+}
+
+//Delete exported
+//Deletes the scoped row keyed by m as Remove does.
+//Versioned rows must hold the version they had when read.
+func (Repo[T]) Delete(ctx context.Context, m T) error {
+
+	c := ginContext(ctx)
+	match(c, ctx, m)
+
+	id, err := keyed(m, fields(m).Primary)
+	if err != nil {
+		return err
+	}
+	return Remove(c, m, id)
+}
+
+//known returns a ParamError if opts refers to columns not in meta or
+//to unknown operators, or orders by anything but a column and a direction
+func known(meta Meta, opts SelectOpt) error {
+
+	unknown := func(k string) error {
+		e := new(ParamError)
+		e.Copy(msg.Get("47").SetArgs(k)) //Unknown column %s
+		return e
+	}
+	operator := func(op string) error {
+		e := new(ParamError)
+		e.Copy(msg.Get("63").SetArgs(op)) //Unknown operator %s
+		return e
+	}
+
+	var groups func(gs []FilterGroup) error
+	groups = func(gs []FilterGroup) error {
+		for _, g := range gs {
+			for _, f := range g.Filter {
+				if op, _ := f.A.(string); !isOperator(op) {
+					return operator(op)
+				} else if k, _ := f.B.(string); !slice.Contains(meta.Ordered, k) {
+					return unknown(k)
+				}
+			}
+			if err := groups(g.Group); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cols := append(append(append([]string{}, opts.Column...), opts.Null...), opts.NotNull...)
+	for op, pairs := range opts.Filter {
+		if !isOperator(op) {
+			return operator(op)
+		}
+		for _, p := range pairs {
+			k, _ := p.A.(string)
+			cols = append(cols, k)
+		}
+	}
+	for _, k := range cols {
+		if !slice.Contains(meta.Ordered, k) {
+			return unknown(k)
+		}
+	}
+
+	for _, o := range opts.Order {
+		j := strings.Fields(o)
+		if len(j) == 0 || len(j) > 2 || !slice.Contains(meta.Ordered, j[0]) {
+			return unknown(o)
+		} else if len(j) == 2 && strings.ToUpper(j[1]) != "ASC" && strings.ToUpper(j[1]) != "DESC" {
+			return unknown(o)
+		}
+	}
+
+	return groups(opts.Group)
+}
+
+//ginContext returns ctx if it's a *gin.Context, otherwise a new one
+//holding a request bound to ctx, with no params, query or headers
+func ginContext(ctx context.Context) *gin.Context {
+
+	if c, ok := ctx.(*gin.Context); ok {
+		return c
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.URL = &url.URL{Path: "/"}
+	return &gin.Context{Request: r.WithContext(ctx)}
+}
+
+//match sets the If-Match header of the request made up for ctx
+//to the version of m, requests from clients are left alone
+func match(c *gin.Context, ctx context.Context, m Model) {
+
+	if _, ok := ctx.(*gin.Context); ok {
+		return
+	} else if tag := ETag(m); tag != "" {
+		c.Request.Header.Set("If-Match", tag)
+	}
+}
+
+//fields returns the metadata of m
+func fields(m Model) Meta {
+
+	meta, _ := Fields(m)
+	return meta
+}
+
+//newT returns a new T
+func newT[T Model]() T {
+
+	var zero T
+	return zero.New().(T)
+}
+
+//typed returns the row v, as Model.Val returns it, as a T
+func typed[T Model](v interface{}) T {
+
+	if t, ok := v.(T); ok {
+		return t
+	}
+
+	//Val returns the struct T points to
+	p := reflect.New(reflect.TypeOf(v))
+	p.Elem().Set(reflect.ValueOf(v))
+	return p.Interface().(T)
+}
+
+//pairs returns the primary key of m paired with id values
+func pairs(m Model, id []interface{}) ([]lib.Pair, error) {
+
+	var (
+		fields, _ = Fields(m)
+		pIDs      []lib.Pair
+	)
+
+	if len(id) != len(fields.Primary) {
+		e := new(ParamError)
+		e.Copy(msg.Get("26")) //Composite key missuse
+		return nil, e
+	}
+
+	for i, k := range fields.Primary {
+		pIDs = append(pIDs, lib.Pair{A: k, B: strings.TrimSpace(fmt.Sprintf("%v", id[i]))})
+	}
+	return pIDs, nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zicare/go-rpg/lib"
+)
+
+func TestRepo(t *testing.T) {
+
+	var (
+		ctx   = context.Background()
+		items = Repo[*item]{}
+	)
+
+	schema(t, itemTable, "DELETE FROM items", "DELETE FROM sqlite_sequence WHERE name = 'items'")
+
+	//create
+	for _, name := range []string{"a", "b", "c"} {
+		if m, err := items.Create(ctx, &item{Name: str(name)}); err != nil {
+			t.Fatalf("Create(%s): %v", name, err)
+		} else if m.ID == nil || *m.Version != 1 {
+			t.Errorf("Create(%s) = %+v, want id and version 1", name, m)
+		}
+	}
+
+	//list, all columns and param.icpp rows by default
+	list, meta, err := items.List(ctx, SelectOpt{Order: []string{"name DESC"}})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(list) != 3 || *list[0].Name != "c" || list[0].Version == nil || meta.Range != "0-2/3" {
+		t.Errorf("List = %d rows, range %s, want 3 rows, range 0-2/3", len(list), meta.Range)
+	}
+
+	//unknown columns and operators
+	for _, opts := range []SelectOpt{
+		{Column: []string{"nope"}},
+		{Order: []string{"name; DROP TABLE items"}},
+		{Filter: map[string][]lib.Pair{"eq": {{A: "nope", B: "1"}}}},
+		{Group: []FilterGroup{{Conj: "or", Group: []FilterGroup{{Conj: "and", Filter: []lib.Triplet{{A: "eq", B: "nope", C: "1"}}}}}}},
+		//unknown operators
+		{Filter: map[string][]lib.Pair{"eq; --": {{A: "name", B: "a"}}}},
+		{Group: []FilterGroup{{Conj: "and", Filter: []lib.Triplet{{A: "nope", B: "name", C: "a"}}}}},
+	} {
+		if _, _, err := items.List(ctx, opts); err == nil {
+			t.Errorf("List(%+v) = nil, want ParamError", opts)
+		} else if _, ok := err.(*ParamError); !ok {
+			t.Errorf("List(%+v) = %T, want ParamError", opts, err)
+		}
+	}
+
+	//get
+	m, err := items.Get(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if *m.Name != "b" {
+		t.Errorf("Get(2) = %s, want b", *m.Name)
+	}
+
+	//update, m holds the version read
+	m.Name = str("bb")
+	if m, err = items.Update(ctx, m); err != nil {
+		t.Fatal(err)
+	} else if *m.Name != "bb" || *m.Version != 2 {
+		t.Errorf("Update = %s version %d, want bb version 2", *m.Name, *m.Version)
+	}
+
+	//stale version
+	stale := &item{ID: m.ID, Name: str("x"), Version: new(int64)}
+	*stale.Version = 1
+	if _, err := items.Update(ctx, stale); err == nil {
+		t.Error("Update(stale) = nil, want PreconditionError")
+	}
+
+	//delete
+	if err := items.Delete(ctx, m); err != nil {
+		t.Fatal(err)
+	} else if _, err := items.Get(ctx, 2); err == nil {
+		t.Error("Get(2) after Delete = nil, want NotFoundError")
+	} else if _, ok := err.(*NotFoundError); !ok || err.Error() == "" {
+		t.Errorf("Get(2) after Delete = %T %v, want NotFoundError", err, err)
+	}
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zicare/go-rpg/msg"
+	"github.com/zicare/go-rpg/slice"
+)
+
+/*
+ * Schema verification
+ *
+ * A mistyped db tag would otherwise show up as a server error the first
+ * time the column is scanned. Verify compares the registered models
+ * against the db schema upfront: Table() and View() must exist and hold
+ * the tagged columns, columns scanned into fields other than pointers or
+ * sql.Scanner implementations must not be nullable, column types must fit
+ * the field types and the primary columns must be the primary key of
+ * Table(). Views don't tell nullability nor primary keys, models whose
+ * Table() is a view skip those checks. Describing honors the query
+ * timeout. Init does it for the models given, i.e.
+ *
+ *	if err := db.Init(new(Person), new(Order)); err != nil {
+ *		log.Fatal(err)
+ *	}
+ */
+
+//SchemaError exported
+//The mismatches between the models and the db schema found by Verify.
+//Messages name the model field involved, i.e. Person.Email.
+type SchemaError struct {
+	Messages msg.MessageList
+}
+
+//Error exported
+func (e *SchemaError) Error() string {
+	return e.Messages.Error()
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+//Verify exported
+//Registers the models given and checks them against the db schema,
+//returning a SchemaError listing all the mismatches found
+func Verify(models ...Model) error {
+
+	if err := Register(models...); err != nil {
+		return err
+	}
+
+	all := append([]Model{}, models...)
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Table() < all[j].Table()
+	})
+
+	e := new(SchemaError)
+	for _, m := range all {
+		ml, err := verify(m)
+		if err != nil {
+			//Server error: %s
+			return msg.Get("25").SetArgs(err.Error()).M2E()
+		}
+		e.Messages = append(e.Messages, ml...)
+	}
+
+	if len(e.Messages) > 0 {
+		return e
+	}
+	return nil
+}
+
+//verify returns the mismatches between m and the db schema
+func verify(m Model) (ml msg.MessageList, err error) {
+
+	var (
+		r           = lookup(m)
+		t           = reflect.Indirect(reflect.ValueOf(m.Val())).Type()
+		table, view map[string]Column
+		primary     []string
+		ctx, cancel = Context(nil, m)
+	)
+	defer cancel()
+
+	if table, err = dialect.Describe(ctx, db, m.Table()); err != nil {
+		return nil, fmt.Errorf("%s: %v", m.Table(), err)
+	} else if len(table) == 0 {
+		//Table %s doesn't exist
+		ml = append(ml, msg.Get("49").SetArgs(m.Table()).SetField(t.Name()))
+	}
+
+	view = table
+	if m.View() != m.Table() {
+		if view, err = dialect.Describe(ctx, db, m.View()); err != nil {
+			return nil, fmt.Errorf("%s: %v", m.View(), err)
+		} else if len(view) == 0 {
+			//Table %s doesn't exist
+			ml = append(ml, msg.Get("49").SetArgs(m.View()).SetField(t.Name()))
+		}
+	}
+
+	for i, k := range r.meta.Ordered {
+		var (
+			f          = t.Field(r.index[i])
+			field      = t.Name() + "." + f.Name
+			tc, inT    = table[k]
+			vc, inView = view[k]
+		)
+
+		//writable columns are in the table, all of them in the view
+		if len(table) > 0 && !inT && !slice.Contains(r.meta.View, k) ||
+			len(view) > 0 && !inView {
+			//Column %s doesn't exist
+			ml = append(ml, msg.Get("50").SetArgs(k).SetField(field))
+			continue
+		} else if !inT && !inView {
+			continue
+		}
+
+		//views don't tell nullability, tables do
+		if inT && !tc.View && tc.Nullable && !nullable(f.Type) {
+			//Column %s is nullable
+			ml = append(ml, msg.Get("51").SetArgs(k).SetField(field))
+		}
+
+		if !inT {
+			tc = vc
+		}
+		if !fits(f.Type, tc.Type) {
+			//Column type %s doesn't fit the field
+			ml = append(ml, msg.Get("52").SetArgs(tc.Type).SetField(field))
+		}
+	}
+
+	if len(table) > 0 && !isView(table) {
+		for _, c := range table {
+			if c.Primary {
+				primary = append(primary, c.Name)
+			}
+		}
+		sort.Strings(primary)
+		tagged := append([]string{}, r.meta.Primary...)
+		sort.Strings(tagged)
+		if strings.Join(primary, ",") != strings.Join(tagged, ",") {
+			//Primary key is (%s)
+			ml = append(ml, msg.Get("53").SetArgs(strings.Join(primary, ", ")).SetField(t.Name()))
+		}
+	}
+	return ml, nil
+}
+
+//isView tells if the columns described belong to a view
+func isView(cols map[string]Column) bool {
+
+	for _, c := range cols {
+		return c.View
+	}
+	return false
+}
+
+//nullable tells if fields of type t can be scanned NULL into
+func nullable(t reflect.Type) bool {
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	}
+	return reflect.PtrTo(t).Implements(scannerType)
+}
+
+//fits tells if values of the db type named can be scanned into
+//fields of type t, loosely, as named types vary among engines
+func fits(t reflect.Type, dbType string) bool {
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	dbType = strings.ToLower(dbType)
+	is := func(names ...string) bool {
+		for _, name := range names {
+			if strings.Contains(dbType, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case dbType == "", reflect.PtrTo(t).Implements(scannerType):
+		//untyped sqlite columns and custom types
+		return true
+	case t == timeType:
+		return is("time", "date")
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return is("int", "serial", "year")
+	case reflect.Float32, reflect.Float64:
+		return is("int", "real", "double", "float", "numeric", "decimal")
+	case reflect.Bool:
+		return is("bool", "int", "bit")
+	case reflect.String, reflect.Interface:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
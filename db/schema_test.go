@@ -0,0 +1,98 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/lib"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//named reads items from the table given, not null columns
+//are scanned into plain fields
+type named struct {
+	ID    int64  `db:"id"   json:"id"   primary:"1"`
+	Name  string `db:"name" json:"name"`
+	table string
+}
+
+func (n *named) New() Model {
+	return &named{table: n.table}
+}
+
+func (n *named) Table() string {
+	return n.table
+}
+
+func (n *named) View() string {
+	return n.table
+}
+
+func (n *named) Val() interface{} {
+	return *n
+}
+
+func (n *named) Xfrm(c *gin.Context) Model {
+	return n
+}
+
+func (n *named) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*named) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*named) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return ErrDefaultDelete
+}
+
+func (*named) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+func TestVerifyViews(t *testing.T) {
+
+	schema(t, itemTable, "CREATE VIEW IF NOT EXISTS item_names AS SELECT id, name FROM items")
+
+	for _, tc := range []struct {
+		table string
+		codes []string
+	}{
+		//name is nullable
+		{"items", []string{"51"}},
+		//views tell neither nullability nor primary keys
+		{"item_names", nil},
+		{"nowhere", []string{"49"}},
+	} {
+		ml, err := verify(&named{table: tc.table})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.table, err)
+		}
+		var codes []string
+		for _, m := range ml {
+			codes = append(codes, m.Key)
+		}
+		if len(codes) != len(tc.codes) || len(codes) > 0 && codes[0] != tc.codes[0] {
+			t.Errorf("%s: got %v, want %v", tc.table, codes, tc.codes)
+		}
+	}
+}
+
+func TestVerifyModelsGiven(t *testing.T) {
+
+	schema(t, itemTable)
+
+	//same type, different tables, other registered models aren't checked
+	err := Verify(&named{table: "nowhere"}, &named{table: "items"})
+	e, ok := err.(*SchemaError)
+	if !ok {
+		t.Fatalf("got %T, want *SchemaError", err)
+	}
+
+	var codes []string
+	for _, m := range e.Messages {
+		codes = append(codes, m.Key)
+	}
+	if len(codes) != 2 || codes[0] != "51" || codes[1] != "49" {
+		t.Errorf("got %v, want [51 49]", codes)
+	}
+}
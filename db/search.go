@@ -0,0 +1,57 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/huandu/go-sqlbuilder"
+)
+
+/*
+ * Full-text search
+ *
+ * ?q= matches the rows of models with text columns tagged search:"1",
+ * or with a full-text column tagged search:"tsvector", i.e.
+ *
+ *	Title *string `db:"title" json:"title" search:"1"`
+ *	Body  *string `db:"body"  json:"body"  search:"1"`
+ *	Tsv   *string `db:"tsv"   json:"-"     search:"tsvector" view:"0"`
+ *
+ * With postgres the query is parsed with websearch_to_tsquery and, if
+ * ?order= isn't sent, rows are sorted by ts_rank. ?headline=1 adds the
+ * matches highlighted by ts_headline as the headline member of each row.
+ * Other dialects are told in Dialect.Search. The match combines with the
+ * scope and filters, and models without search columns ignore ?q=.
+ */
+
+//search returns the expressions matching rows of m against the
+//?q= query, ranking and highlighting them, all empty without one
+func search(m Model, cb *sqlbuilder.Cond, opt SelectOpt, table string) (match string, rank string, headline string) {
+
+	fields, _ := Fields(m)
+	if opt.Search == "" || len(fields.Search) == 0 && fields.Vector == "" {
+		return "", "", ""
+	}
+
+	vector := ""
+	if fields.Vector != "" {
+		vector = table + "." + fields.Vector
+	}
+	return dialect.Search(cb, prefix(fields.Search, table), vector, opt.Search)
+}
+
+//highlight returns v as a JSON object holding the headline member too,
+//numbers are kept as they are, int64 ones wouldn't fit a float64
+func highlight(v interface{}, headline *string) interface{} {
+
+	var obj map[string]interface{}
+
+	b, _ := json.Marshal(v)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return v
+	}
+	obj["headline"] = headline
+	return obj
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/huandu/go-sqlbuilder"
+)
+
+func TestHighlightKeepsInt64(t *testing.T) {
+
+	var (
+		h   = "a"
+		row = struct {
+			ID int64 `json:"id"`
+		}{1<<62 + 1}
+	)
+
+	b, _ := json.Marshal(highlight(row, &h))
+	if want := `{"headline":"a","id":4611686018427387905}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestSearchEscapesWildcards(t *testing.T) {
+
+	items(t, "50% off", "500 off", "5_0")
+
+	for q, want := range map[string]int{"0%": 1, "50": 2, "_": 1} {
+		var (
+			n  int
+			sb = sqlbuilder.SQLite.NewSelectBuilder()
+		)
+		match, _, _ := sqlite{}.Search(&sb.Cond, []string{"name"}, "", q)
+		sb.Select("COUNT(*)").From("items").Where(match)
+		query, args := sb.Build()
+		if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatal(err)
+		} else if n != want {
+			t.Errorf("%q: got %d rows, want %d", q, n, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/msg"
+)
+
+/*
+ * Soft delete
+ *
+ * A model with a column tagged softdelete:"1" has its rows stamped on
+ * delete instead of removed, i.e.
+ *
+ *	DeletedAt *time.Time `db:"deleted_at" json:"-" softdelete:"1"`
+ *
+ * Stamped rows are left out of FetchAll, Find and Update. Models
+ * implementing TrashModel may let some requests see them with
+ * ?with_deleted=1. Restore brings them back for models implementing
+ * RestoreModel, to the requests it allows.
+ */
+
+//TrashModel exported
+type TrashModel interface {
+	Model
+	WithDeleted(*gin.Context) bool
+}
+
+//RestoreModel exported
+//Models implementing it let the requests CanRestore allows restore their
+//rows, on top of Model.Scope. Rows of other models can't be restored.
+type RestoreModel interface {
+	Model
+	CanRestore(*gin.Context) bool
+}
+
+//SoftDelete exported
+//Stamps the softdelete column of the scoped row,
+//versioned rows are deleted as in Update
+func SoftDelete(c *gin.Context, m Model, pIDs []lib.Pair) error {
+
+	fields, _ := Fields(m)
+	match, err := IfMatch(c, m)
+	if err != nil {
+		return err
+	}
+	return stamp(c, m, pIDs, fields.SoftDelete, time.Now(), false, match)
+}
+
+//Restore exported
+//Clears the softdelete column of the scoped row identified by the
+//id param and reads it back. Returns NotAllowedError unless m is
+//a RestoreModel allowing the request.
+func Restore(c *gin.Context, m Model) error {
+
+	fields, _ := Fields(m)
+
+	if rm, ok := m.(RestoreModel); !ok || !rm.CanRestore(c) {
+		e := new(NotAllowedError)
+		e.Copy(msg.Get("8")) //Not enough permissions
+		return e
+	} else if id, err := ParamIDs(c, m); err != nil {
+		return err //*ParamError
+	} else if err := stamp(c, m, id, fields.SoftDelete, nil, true, nil); err != nil {
+		return err
+	} else {
+		return find(c, m, id, true)
+	}
+}
+
+//stamp sets the softdelete column k to v on rows deleted or not,
+//holding any of the versions in match if versioned
+func stamp(c *gin.Context, m Model, id []lib.Pair, k string, v interface{}, deleted bool, match []string) error {
+
+	var (
+		table = m.Table()
+		ub    = Flavor().NewUpdateBuilder()
+	)
+
+	if k == "" {
+		e := new(ParamError)
+		e.Copy(msg.Get("37")) //Model doesn't support soft delete
+		return e
+	}
+
+	ub.Update(table)
+	if b := bump(ub, m); b != "" {
+		ub.Set(ub.Assign(k, v), b)
+	} else {
+		ub.Set(ub.Assign(k, v))
+	}
+
+	m.Scope(ub, c)
+
+	if deleted {
+		ub.Where(ub.IsNotNull(k))
+	} else {
+		ub.Where(ub.IsNull(k))
+	}
+
+	for _, p := range id {
+		ub.Where(ub.Equal(p.A.(string), p.B.(string)))
+	}
+
+	if e := under(c, m, &ub.Cond, table); e != "" {
+		ub.Where(e)
+	}
+
+	if e, err := versioned(&ub.Cond, m, match); err != nil {
+		return err
+	} else if e != "" {
+		ub.Where(e)
+	}
+
+	ctx, cancel := Context(c, m)
+	defer cancel()
+
+	sql, args := ub.Build()
+	//fmt.Println(sql, args)
+	if res, err := Conn(c).ExecContext(ctx, sql, args...); err != nil {
+		//Server error: %s
+		return fail(c, err)
+	} else if rows, _ := res.RowsAffected(); rows == 0 {
+		//not found, out of scope or version mismatch
+		return missed(c, m, id, match)
+	}
+	return nil
+}
+
+//live returns the where expression leaving soft deleted rows out,
+//empty if m isn't soft deleted or the request may see deleted rows
+func live(c *gin.Context, m Model, cb *sqlbuilder.Cond, table string) string {
+
+	fields, _ := Fields(m)
+	if fields.SoftDelete == "" {
+		return ""
+	} else if tm, ok := m.(TrashModel); ok && c != nil &&
+		c.Query("with_deleted") == "1" && tm.WithDeleted(c) {
+		return ""
+	}
+	return cb.IsNull(fmt.Sprintf("%s.%s", table, fields.SoftDelete))
+}
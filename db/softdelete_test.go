@@ -0,0 +1,175 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/lib"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//memo is soft deleted, requests with X-Admin may see and restore
+//deleted memos
+type memo struct {
+	ID        *int64     `db:"id"         json:"id"   primary:"1" serial:"1"`
+	Text      *string    `db:"text"       json:"text"`
+	DeletedAt *time.Time `db:"deleted_at" json:"-"    softdelete:"1"`
+}
+
+func (*memo) New() Model {
+	return new(memo)
+}
+
+func (*memo) Table() string {
+	return "memos"
+}
+
+func (*memo) View() string {
+	return "memos"
+}
+
+func (n *memo) Val() interface{} {
+	return *n
+}
+
+func (n *memo) Xfrm(c *gin.Context) Model {
+	return n
+}
+
+func (n *memo) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*memo) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*memo) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return ErrDefaultDelete
+}
+
+func (*memo) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+func (*memo) WithDeleted(c *gin.Context) bool {
+	return c.GetHeader("X-Admin") == "1"
+}
+
+func (*memo) CanRestore(c *gin.Context) bool {
+	return c.GetHeader("X-Admin") == "1"
+}
+
+//memos empties the memos table and inserts the texts given,
+//numbered from 1
+func memos(t testing.TB, texts ...string) {
+
+	t.Helper()
+	schema(t, `CREATE TABLE IF NOT EXISTS memos (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		text       VARCHAR(100),
+		deleted_at DATETIME
+	)`, "DELETE FROM memos", "DELETE FROM sqlite_sequence WHERE name = 'memos'")
+	for _, s := range texts {
+		schema(t, fmt.Sprintf("INSERT INTO memos (text) VALUES ('%s')", s))
+	}
+}
+
+//texts returns the texts of the memos FetchAll gets for target
+func texts(t *testing.T, target string, headers ...string) (s []string) {
+
+	t.Helper()
+	_, rows, err := FetchAll(request("GET", target, headers...), new(memo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rows {
+		s = append(s, *r.(memo).Text)
+	}
+	return
+}
+
+func TestSoftDeleteStamps(t *testing.T) {
+
+	memos(t, "a", "b")
+
+	if err := Remove(request("DELETE", "/memos/1"), new(memo), []lib.Pair{{A: "id", B: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var stamped int
+	if err := Db().QueryRow("SELECT count(*) FROM memos WHERE id = 1 AND deleted_at IS NOT NULL").Scan(&stamped); err != nil {
+		t.Fatal(err)
+	} else if stamped != 1 {
+		t.Error("row 1 wasn't kept and stamped")
+	}
+
+	//already deleted
+	err := Delete(request("DELETE", "/memos/1"), new(memo), []lib.Pair{{A: "id", B: "1"}})
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Errorf("deleting twice got %T, want *NotFoundError", err)
+	}
+}
+
+func TestSoftDeleteHidesRows(t *testing.T) {
+
+	memos(t, "a", "b")
+	schema(t, "UPDATE memos SET deleted_at = CURRENT_TIMESTAMP WHERE id = 1")
+
+	for _, tc := range []struct {
+		target  string
+		headers []string
+		want    string
+	}{
+		{"/memos", nil, "[b]"},
+		{"/memos?with_deleted=1", nil, "[b]"},
+		{"/memos", []string{"X-Admin", "1"}, "[b]"},
+		{"/memos?with_deleted=1", []string{"X-Admin", "1"}, "[a b]"},
+	} {
+		if got := fmt.Sprint(texts(t, tc.target, tc.headers...)); got != tc.want {
+			t.Errorf("%s %v: got %s, want %s", tc.target, tc.headers, got, tc.want)
+		}
+	}
+
+	c := request("GET", "/memos/1")
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	if err := Find(c, new(memo)); err == nil {
+		t.Error("found a deleted row")
+	} else if _, ok := err.(*NotFoundError); !ok {
+		t.Errorf("got %T, want *NotFoundError", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+
+	memos(t, "a", "b")
+	schema(t, "UPDATE memos SET deleted_at = CURRENT_TIMESTAMP WHERE id = 1")
+
+	for _, tc := range []struct {
+		id      string
+		headers []string
+		err     error
+	}{
+		{"1", nil, new(NotAllowedError)},
+		{"1", []string{"X-Admin", "1"}, nil},
+		{"1", []string{"X-Admin", "1"}, new(NotFoundError)},
+		{"2", []string{"X-Admin", "1"}, new(NotFoundError)},
+	} {
+		var (
+			m = new(memo)
+			c = request("POST", "/memos/"+tc.id+"/restore", tc.headers...)
+		)
+		c.Params = gin.Params{{Key: "id", Value: tc.id}}
+		err := Restore(c, m)
+		if tc.err == nil && err != nil {
+			t.Errorf("%s %v: got %v, want no error", tc.id, tc.headers, err)
+		} else if tc.err != nil && fmt.Sprintf("%T", err) != fmt.Sprintf("%T", tc.err) {
+			t.Errorf("%s %v: got %T, want %T", tc.id, tc.headers, err, tc.err)
+		} else if err == nil && (m.DeletedAt != nil || *m.Text != "a") {
+			t.Errorf("%s %v: read back %+v", tc.id, tc.headers, m)
+		}
+	}
+
+	if got := fmt.Sprint(texts(t, "/memos")); got != "[a b]" {
+		t.Errorf("got %s after restoring, want [a b]", got)
+	}
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/config"
+)
+
+//Streaming exported
+//Tells if the collection is better streamed than fetched at once, that
+//is if the limit is over param.stream_threshold, never if it isn't set.
+//Streamed responses have no ETag, as it's a digest of the whole response,
+//so requests holding If-None-Match aren't streamed, nor ?stream=0 ones.
+//Responses held back by mw.Tx aren't streamed either.
+func Streaming(c *gin.Context, m Model) bool {
+
+	var (
+		opt       = params(c, m)
+		threshold = config.Config().GetInt("param.stream_threshold")
+	)
+
+	if _, ok := c.Get("Tx"); ok || c.Query("stream") == "0" || c.GetHeader("If-None-Match") != "" {
+		return false
+	}
+	return threshold > 0 && opt.Limit > threshold
+}
+
+//Stream exported
+//Like FetchAll but handing the rows to emit as they're scanned, in
+//chunks of param.stream_chunk rows, 100 by default. head is called
+//with the meta before the first chunk, never if there are no rows.
+//The meta returned holds the cursor to the next page too, and the
+//checksum if requested, the same FetchAll computes.
+func Stream(c *gin.Context, m Model, head func(ResultSetMeta) error, emit func([]interface{}) error) (ResultSetMeta, error) {
+
+	var (
+		opt     = params(c, m)
+		chunk   = config.Config().GetInt("param.stream_chunk")
+		started bool
+		sum     = crc32.NewIEEE()
+		sep     = "["
+	)
+
+	if chunk <= 0 {
+		chunk = 100
+	}
+
+	meta, _, err := fetch(c, m, opt, chunk, func(meta ResultSetMeta, rows []interface{}) error {
+		if !started {
+			started = true
+			if err := head(meta); err != nil {
+				return err
+			}
+		}
+		if opt.Checksum == 1 {
+			//as the JSON array of every row
+			for _, row := range rows {
+				b, _ := json.Marshal(row)
+				sum.Write(append([]byte(sep), b...))
+				sep = ","
+			}
+		}
+		return emit(rows)
+	})
+	if err == nil && started && opt.Checksum == 1 {
+		sum.Write([]byte("]"))
+		meta.Checksum = strconv.FormatUint(uint64(sum.Sum32()), 16)
+	}
+	return meta, err
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/zicare/go-rpg/config"
+)
+
+func TestStreaming(t *testing.T) {
+
+	//not set
+	if Streaming(request("GET", "/items?limit=1000"), new(item)) {
+		t.Error("streamed with no param.stream_threshold")
+	}
+
+	config.Config().Set("param.stream_threshold", 100)
+	defer config.Config().Set("param.stream_threshold", nil)
+
+	for target, want := range map[string]bool{
+		"/items":                      false,
+		"/items?limit=100":            false,
+		"/items?limit=101":            true,
+		"/items?limit=101&stream=0":   false,
+		"/items?limit=101&checksum=1": true,
+	} {
+		if got := Streaming(request("GET", target), new(item)); got != want {
+			t.Errorf("%s: got %v, want %v", target, got, want)
+		}
+	}
+
+	//the client holds a copy, it may be fresh
+	if Streaming(request("GET", "/items?limit=101", "If-None-Match", `"1"`), new(item)) {
+		t.Error("streamed with If-None-Match")
+	}
+}
+
+func TestStreamChecksum(t *testing.T) {
+
+	items(t, "a", "b", "c", "d", "e")
+
+	target := "/items?checksum=1&order=name"
+	fetched, _, err := FetchAll(request("GET", target), new(item))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//in chunks
+	config.Config().Set("param.stream_chunk", 2)
+	defer config.Config().Set("param.stream_chunk", nil)
+
+	var n int
+	streamed, err := Stream(request("GET", target), new(item),
+		func(ResultSetMeta) error { return nil },
+		func(rows []interface{}) error { n += len(rows); return nil })
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 5 || streamed.Checksum != fetched.Checksum {
+		t.Errorf("got %d rows, checksum %s, want 5 rows, checksum %s", n, streamed.Checksum, fetched.Checksum)
+	}
+}
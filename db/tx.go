@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/config"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/msg"
+)
+
+//models being bound, keyed by model, valued by request
+var binding sync.Map
+
+//Querier exported
+//Satisfied by both *sql.DB and *sql.Tx
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+//TimeoutModel exported
+//Models implementing it override the db.query_timeout setting
+type TimeoutModel interface {
+	QueryTimeout() time.Duration
+}
+
+//Context exported
+//Returns the request context bounded by the query timeout of m.
+//Both c and m may be nil, i.e. for queries run on start up.
+func Context(c *gin.Context, m Model) (context.Context, context.CancelFunc) {
+
+	d, _ := time.ParseDuration(config.Config().GetString("db.query_timeout"))
+	if tm, ok := m.(TimeoutModel); ok {
+		d = tm.QueryTimeout()
+	}
+	if d > 0 {
+		return context.WithTimeout(reqCtx(c), d)
+	}
+	return context.WithCancel(reqCtx(c))
+}
+
+func reqCtx(c *gin.Context) context.Context {
+
+	if c == nil || c.Request == nil {
+		return context.Background()
+	}
+	return c.Request.Context()
+}
+
+//Conn exported
+//Returns the transaction bound to the request, if any, or the db handler.
+//Model.Delete implementations should run their queries through it
+//so they take part in the request transaction.
+func Conn(c *gin.Context) Querier {
+
+	if tx, ok := tx(c); ok {
+		return tx
+	}
+	return db
+}
+
+//Request exported
+//Returns the request m is being bound to, if any. Validation
+//rules run within Bind use it to query through Conn and Context.
+func Request(m Model) *gin.Context {
+
+	if v, ok := binding.Load(m); ok {
+		return v.(*gin.Context)
+	}
+	return nil
+}
+
+//bind calls m.Bind keeping c at reach of Request
+func bind(c *gin.Context, m Model, pIDs []lib.Pair) error {
+
+	binding.Store(m, c)
+	defer binding.Delete(m)
+	return m.Bind(c, pIDs)
+}
+
+func tx(c *gin.Context) (*sql.Tx, bool) {
+
+	if c == nil {
+		return nil, false
+	} else if v, exists := c.Get("Tx"); !exists {
+		return nil, false
+	} else if tx, ok := v.(*sql.Tx); ok && tx != nil {
+		return tx, true
+	}
+	return nil, false
+}
+
+//WithTx exported
+//Runs fn within a transaction bound to the request, committed when fn
+//returns nil and rolled back otherwise. When fn or the commit fail on a
+//serialization failure, the whole run is retried up to db.tx_retries times.
+//Transactions run at the db.isolation level, see isolation.
+//If the request is already bound to a transaction fn just joins it.
+func WithTx(c *gin.Context, fn func() error) (err error) {
+
+	if _, ok := tx(c); ok {
+		return fn()
+	}
+
+	retries := 3
+	if config.Config().IsSet("db.tx_retries") {
+		retries = config.Config().GetInt("db.tx_retries")
+	}
+
+	for i := 0; ; i++ {
+		if err = run(c, fn); err == nil || i >= retries {
+			return err
+		} else if _, ok := err.(*SerializationError); !ok {
+			return err
+		}
+	}
+}
+
+func run(c *gin.Context, fn func() error) error {
+
+	t, err := db.BeginTx(reqCtx(c), &sql.TxOptions{Isolation: isolation()})
+	if err != nil {
+		//Server error: %s
+		return fail(c, err)
+	}
+
+	c.Set("Tx", t)
+	defer c.Set("Tx", nil)
+
+	defer func() {
+		if p := recover(); p != nil {
+			t.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(); err != nil {
+		t.Rollback()
+		return err
+	} else if err := t.Commit(); err != nil {
+		//Server error: %s
+		return fail(c, err)
+	}
+	return nil
+}
+
+//isolation returns the isolation level set by db.isolation, i.e.
+//"serializable" or "repeatable read", the db default if not set
+func isolation() sql.IsolationLevel {
+
+	levels := map[string]sql.IsolationLevel{
+		"read uncommitted": sql.LevelReadUncommitted,
+		"read committed":   sql.LevelReadCommitted,
+		"repeatable read":  sql.LevelRepeatableRead,
+		"serializable":     sql.LevelSerializable,
+	}
+	return levels[strings.ToLower(config.Config().GetString("db.isolation"))]
+}
+
+//SerializationFailure exported
+//Returns the serialization failure reported to the request
+//after its n first errors, nil if there's none
+func SerializationFailure(c *gin.Context, n int) error {
+
+	for _, e := range c.Errors[n:] {
+		if se, ok := e.Err.(*SerializationError); ok {
+			return se
+		}
+	}
+	return nil
+}
+
+//fail wraps driver errors. Serialization failures are also
+//reported to the request, so they are known even if the caller
+//doesn't pass them along.
+func fail(c *gin.Context, err error) error {
+
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) ||
+		dialect.Timeout(err) {
+		e := new(TimeoutError)
+		e.Copy(msg.Get("31")) //Query timeout
+		return e
+	} else if dialect.Retry(err) {
+		e := new(SerializationError)
+		e.Copy(msg.Get("25").SetArgs(err.Error())) //Server error: %s
+		if c != nil {
+			c.Error(e)
+		}
+		return e
+	}
+	//Server error: %s
+	return msg.Get("25").SetArgs(err.Error()).M2E()
+}
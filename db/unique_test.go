@@ -0,0 +1,88 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/validation"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//a room is booked once a day
+type booking struct {
+	ID   *int64  `db:"id"   json:"id"   primary:"1" serial:"1"`
+	Day  *string `db:"day"  json:"day"  validate:"unique=day room"`
+	Room *string `db:"room" json:"room"`
+}
+
+func (*booking) New() db.Model {
+	return new(booking)
+}
+
+func (*booking) Table() string {
+	return "bookings"
+}
+
+func (*booking) View() string {
+	return "bookings"
+}
+
+func (b *booking) Val() interface{} {
+	return *b
+}
+
+func (b *booking) Xfrm(c *gin.Context) db.Model {
+	return b
+}
+
+func (b *booking) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return validation.Struct(b)
+}
+
+func (*booking) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*booking) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return db.ErrDefaultDelete
+}
+
+func (*booking) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+func TestUniqueGroup(t *testing.T) {
+
+	validation.Init()
+
+	for _, q := range []string{
+		"DROP TABLE IF EXISTS bookings",
+		"CREATE TABLE bookings (id INTEGER PRIMARY KEY AUTOINCREMENT, day VARCHAR(10), room VARCHAR(10))",
+		"INSERT INTO bookings (day, room) VALUES ('mon', '1'), ('tue', NULL)",
+	} {
+		if _, err := db.Db().Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		str = func(s string) *string { return &s }
+		one = int64(1)
+	)
+
+	for _, tc := range []struct {
+		b     booking
+		valid bool
+	}{
+		{booking{Day: str("mon"), Room: str("1")}, false},
+		{booking{Day: str("mon"), Room: str("2")}, true},
+		//NULL clashes with nothing, not even NULL
+		{booking{Day: str("mon")}, true},
+		{booking{Day: str("tue")}, true},
+		//the row itself
+		{booking{ID: &one, Day: str("mon"), Room: str("1")}, true},
+	} {
+		if err := validation.Struct(&tc.b); (err == nil) != tc.valid {
+			t.Errorf("%v %v: got %v, want valid %v", tc.b.Day, tc.b.Room, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/msg"
+	"github.com/zicare/go-rpg/slice"
+)
+
+//UpsertModel exported
+//Models implementing it let PUT create the row if the id isn't found
+type UpsertModel interface {
+	Model
+	CreateOnPut() bool
+}
+
+//Upsert exported
+//Updates the row or creates it if there's none, in one statement. The row
+//is identified by the id param if group is empty, or by the columns tagged
+//unique:"group" otherwise. The update is scoped as in Update. Rows found out
+//of the scope are neither updated nor created, and NotFoundError is returned.
+//Returns true if the row was created, then a NotFoundError means the row was
+//created out of the read scope. Versioned rows are updated as in Update,
+//without the If-Match header the row may only be created.
+func Upsert(c *gin.Context, m Model, group string) (created bool, err error) {
+
+	var (
+		id        []lib.Pair
+		fields, _ = Fields(m)
+		conflict  = fields.Primary
+	)
+
+	match, required := IfMatch(c, m)
+
+	if group == "" {
+		if id, err = ParamIDs(c, m); err != nil {
+			//composite key misuse
+			return false, err
+		} else if err = bind(c, m, id); err != nil {
+			//payload problem
+			return false, err
+		}
+		//the id param rules over the payload
+		for _, p := range id {
+			if err = set(m, p.A.(string), p.B.(string)); err != nil {
+				return false, err
+			}
+		}
+	} else {
+		if conflict = unique(m, group); len(conflict) == 0 {
+			e := new(ParamError)
+			e.Copy(msg.Get("36").SetArgs(group)) //Unknown unique group %s
+			return false, e
+		} else if err = bind(c, m, []lib.Pair{}); err != nil {
+			//payload problem
+			return false, err
+		} else if id, err = keyed(m, conflict); err != nil {
+			return false, err
+		}
+	}
+
+	var scope error
+	err = WithTx(c, func() error {
+		var (
+			updated bool
+			err     error
+		)
+		scope = nil
+		if created, updated, err = upsert(c, m, id, conflict, match, required == nil); err != nil {
+			return err
+		} else if !created && !updated && required != nil {
+			//it exists but its version wasn't given
+			return required
+		} else if !created && !updated {
+			//out of scope or version mismatch
+			return missed(c, m, id, match)
+		} else if err := find(c, m, id, false); err == nil {
+			return nil
+		} else if _, ok := err.(*NotFoundError); ok && created {
+			//created out of the read scope, the row stays
+			scope = err
+			return nil
+		} else {
+			return err
+		}
+	})
+
+	if err == nil {
+		err = scope
+	}
+	return created, err
+}
+
+//upsert writes the bound model identified by id, updating instead the row
+//clashing with it on conflict as update does, never if update is false.
+//Tells if the row was created or updated.
+func upsert(c *gin.Context, m Model, id []lib.Pair, conflict []string, match []string, update bool) (bool, bool, error) {
+
+	//nested routes set the parent key
+	if err := adopt(c, m); err != nil {
+		return false, false, err
+	}
+
+	var (
+		table       = m.Table()
+		fields, val = Fields(m)
+		ib          = Flavor().NewInsertBuilder()
+		ub          = Flavor().NewUpdateBuilder()
+		cols, set   []string
+		v, to       []interface{}
+	)
+
+	for _, w := range fields.Writable {
+		if isNull(val[w]) && (w == fields.Version || slice.Contains(fields.Primary, w) && slice.Contains(fields.Serial, w)) {
+			//left to the db
+			continue
+		}
+		cols, v = append(cols, w), append(v, val[w])
+		if !isNull(val[w]) && !slice.Contains(conflict, w) && w != fields.Version {
+			set, to = append(set, w), append(to, val[w])
+		}
+	}
+	if fields.Version != "" {
+		set, to = append(set, fields.Version), append(to, next(m, table+"."+fields.Version))
+	}
+
+	//the condition on the row clashing
+	if !update {
+		ub.Where("1 = 0")
+	} else {
+		m.Scope(ub, c)
+		if e := live(c, m, &ub.Cond, table); e != "" {
+			ub.Where(e)
+		}
+		if e := under(c, m, &ub.Cond, table); e != "" {
+			ub.Where(e)
+		}
+		if e, err := versioned(&ub.Cond, m, match); err != nil {
+			return false, false, err
+		} else if e != "" {
+			ub.Where(e)
+		}
+	}
+	for _, p := range id {
+		ub.Where(ub.Equal(p.A.(string), p.B.(string)))
+	}
+
+	ib.InsertInto(table)
+	ib.Cols(cols...)
+	ib.Values(v...)
+
+	ctx, cancel := Context(c, m)
+	defer cancel()
+
+	created, updated, err := dialect.Upsert(ctx, Conn(c), ib, table, conflict, set, to, where{ub.WhereClause})
+	if err != nil {
+		//Server error: %s
+		return false, false, failWrite(c, m, err)
+	}
+	return created, updated, nil
+}
+
+//where is the condition of a where clause, to be nested in other builders
+type where struct {
+	*sqlbuilder.WhereClause
+}
+
+func (w where) Build() (string, []interface{}) {
+	return w.BuildWithFlavor(w.Flavor())
+}
+
+func (w where) BuildWithFlavor(flavor sqlbuilder.Flavor, initialArg ...interface{}) (string, []interface{}) {
+
+	q, args := w.WhereClause.BuildWithFlavor(flavor, initialArg...)
+	if q == "" {
+		return "1 = 1", initialArg
+	}
+	return strings.TrimPrefix(q, "WHERE "), args
+}
+
+//unique returns the columns tagged unique:"group"
+func unique(m Model, group string) (cols []string) {
+
+	var db = TAG(m, "db")
+	for f, g := range TAG(m, "unique") {
+		if g == group {
+			cols = append(cols, db[f])
+		}
+	}
+	return
+}
+
+//keyed returns the values of m for cols, all of them must be set
+func keyed(m Model, cols []string) ([]lib.Pair, error) {
+
+	if id, ok := keys(m, cols); ok {
+		return id, nil
+	}
+	e := new(ParamError)
+	e.Copy(msg.Get("26")) //Composite key missuse
+	return nil, e
+}
+
+//set assigns the column k of m from its string representation
+func set(m Model, k string, v string) error {
+
+	if addr := Struct(m).AddrWithCols([]string{k}, &m); len(addr) == 1 {
+		return assign(addr[0], v)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpsert(t *testing.T) {
+
+	items(t, "a")
+
+	for _, tc := range []struct {
+		id, body, match string
+		created         bool
+		name            string
+		version         int64
+	}{
+		{"1", `{"name": "b"}`, "*", false, "b", 2},
+		{"2", `{"name": "c"}`, "", true, "c", 1},
+		{"2", `{"name": "d"}`, `"1"`, false, "d", 2},
+	} {
+		c := send("PUT", "/items/"+tc.id, tc.body, "Content-Type", "application/json", "If-Match", tc.match)
+		c.Params = gin.Params{{Key: "id", Value: tc.id}}
+		m := new(item)
+		if created, err := Upsert(c, m, ""); err != nil {
+			t.Fatalf("%s: %v", tc.id, err)
+		} else if created != tc.created || *m.Name != tc.name || *m.Version != tc.version {
+			t.Errorf("%s: got %v %s version %d, want %v %s version %d",
+				tc.id, created, *m.Name, *m.Version, tc.created, tc.name, tc.version)
+		}
+	}
+
+	//the row exists but its version wasn't given
+	c := send("PUT", "/items/1", `{"name": "e"}`, "Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	if _, err := Upsert(c, new(item), ""); err == nil {
+		t.Error("got no error, want PreconditionRequiredError")
+	} else if _, ok := err.(*PreconditionRequiredError); !ok {
+		t.Errorf("got %T, want PreconditionRequiredError", err)
+	}
+
+	//stale version
+	c = send("PUT", "/items/1", `{"name": "e"}`, "Content-Type", "application/json", "If-Match", `"1"`)
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	if _, err := Upsert(c, new(item), ""); err == nil {
+		t.Error("got no error, want PreconditionError")
+	} else if _, ok := err.(*PreconditionError); !ok {
+		t.Errorf("got %T, want PreconditionError", err)
+	}
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/msg"
+)
+
+/*
+ * Optimistic concurrency
+ *
+ * A model with an integer or timestamp column tagged version:"1" is
+ * only updated or deleted if the client holds its current version, i.e.
+ *
+ *	Version *int64 `db:"version" json:"version" version:"1"`
+ *
+ * The version is sent as the ETag of the row and expected back in the
+ * If-Match header. GET responses append a digest of the representation
+ * to it after a +, i.e. "3+9f0c2e1d", If-Match takes the version only. Every write moves it forward, integers are incremented
+ * and timestamps set to the current time. The column should have a
+ * default, i.e. DEFAULT 1 or DEFAULT now(), so new rows get one.
+ */
+
+//ETag exported
+//Returns the strong entity tag holding the version of m,
+//empty if m has no version column or it's not set
+func ETag(m Model) string {
+
+	fields, val := Fields(m)
+	if fields.Version == "" {
+		return ""
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(val[fields.Version]))
+	if !v.IsValid() {
+		return ""
+	} else if t, ok := v.Interface().(time.Time); ok {
+		return `"` + t.UTC().Format(time.RFC3339Nano) + `"`
+	}
+	return fmt.Sprintf(`"%v"`, v.Interface())
+}
+
+//IfMatch exported
+//Returns the versions listed in the If-Match header for models with
+//a version column. Returns nil if m has no version column or the header
+//is *, and PreconditionRequiredError if the header is missing.
+func IfMatch(c *gin.Context, m Model) ([]string, error) {
+
+	if fields, _ := Fields(m); fields.Version == "" {
+		return nil, nil
+	}
+
+	h := strings.TrimSpace(c.GetHeader("If-Match"))
+	if h == "" {
+		e := new(PreconditionRequiredError)
+		e.Copy(msg.Get("45")) //If-Match header is required
+		return nil, e
+	} else if h == "*" {
+		return nil, nil
+	}
+
+	match := []string{}
+	for _, tag := range strings.Split(h, ",") {
+		//weak tags never match
+		if tag = strings.TrimSpace(tag); len(tag) > 1 && tag[0] == '"' && tag[len(tag)-1] == '"' {
+			//the version, without the digest of the representation
+			match = append(match, strings.SplitN(tag[1:len(tag)-1], "+", 2)[0])
+		}
+	}
+	return match, nil
+}
+
+//versioned returns the where expression selecting rows of m holding
+//any of the versions in match, empty if match is nil
+func versioned(cb *sqlbuilder.Cond, m Model, match []string) (string, error) {
+
+	if match == nil {
+		return "", nil
+	}
+
+	var (
+		fields, val = Fields(m)
+		t           = reflect.TypeOf(val[fields.Version])
+		vals        []interface{}
+		e           = new(PreconditionError)
+	)
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for _, s := range match {
+		var (
+			v   interface{}
+			err error
+		)
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v, err = strconv.ParseInt(s, 10, 64)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v, err = strconv.ParseUint(s, 10, 64)
+		default:
+			if t == reflect.TypeOf(time.Time{}) {
+				v, err = time.Parse(time.RFC3339Nano, s)
+			} else {
+				v = s
+			}
+		}
+		if err == nil {
+			vals = append(vals, v)
+		}
+	}
+
+	if len(vals) == 0 {
+		//nothing could match
+		e.Copy(msg.Get("44")) //Row was modified by someone else
+		return "", e
+	}
+	return cb.In(fields.Version, vals...), nil
+}
+
+//bump returns the assignment moving the version column of m forward,
+//empty if m has no version column
+func bump(ub *sqlbuilder.UpdateBuilder, m Model) string {
+
+	fields, _ := Fields(m)
+	if fields.Version == "" {
+		return ""
+	}
+	return ub.Assign(fields.Version, next(m, fields.Version))
+}
+
+//next returns the value moving the version column of m forward,
+//col being how the current one is referred to
+func next(m Model, col string) interface{} {
+
+	fields, val := Fields(m)
+	t := reflect.TypeOf(val[fields.Version])
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return time.Now()
+	}
+	return sqlbuilder.Raw(col + " + 1")
+}
+
+//precondition returns PreconditionError if the scoped row of m doesn't
+//hold any of the versions in match, NotFoundError if there's none
+func precondition(c *gin.Context, m Model, id []lib.Pair, match []string) error {
+
+	if match == nil {
+		return nil
+	}
+
+	cur := m.New()
+	if err := find(c, cur, id, true); err != nil {
+		return err
+	}
+	for _, v := range match {
+		if `"`+v+`"` == ETag(cur) {
+			return nil
+		}
+	}
+	e := new(PreconditionError)
+	e.Copy(msg.Get("44")) //Row was modified by someone else
+	return e
+}
+
+//missed returns the error for a versioned write that changed no row,
+//PreconditionError if the row is still found, NotFoundError otherwise
+func missed(c *gin.Context, m Model, id []lib.Pair, match []string) error {
+
+	if match != nil {
+		if err := find(c, m.New(), id, true); err == nil {
+			e := new(PreconditionError)
+			e.Copy(msg.Get("44")) //Row was modified by someone else
+			return e
+		} else if _, ok := err.(*NotFoundError); !ok {
+			return err
+		}
+	}
+
+	e := new(NotFoundError)
+	e.Copy(msg.Get("18")) //Not found!
+	return e
+}
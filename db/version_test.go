@@ -0,0 +1,15 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestIfMatchTakesTheVersion(t *testing.T) {
+
+	c := request("PUT", "/items/1", "If-Match", `"2+9f0c-csv", W/"3", "4"`)
+	if match, err := IfMatch(c, new(item)); err != nil {
+		t.Fatal(err)
+	} else if len(match) != 2 || match[0] != "2" || match[1] != "4" {
+		t.Errorf("got %v, want [2 4]", match)
+	}
+}
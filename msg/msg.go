@@ -125,4 +125,39 @@ func _init() {
 	msg["27"] = New("27", "Couldn't retrieve Gin's default validator engine")
 	msg["28"] = New("28", "Unauthorized app")
 	msg["29"] = New("29", "CORS tags are not properly set")
+	msg["30"] = New("30", "Invalid cursor")
+	msg["31"] = New("31", "Query timeout")
+	msg["32"] = New("32", "Unsupported db driver %s")
+	msg["33"] = New("33", "Unsupported media type %s")
+	msg["34"] = New("34", "Invalid patch: %s")
+	msg["35"] = New("35", "A JSON array is required")
+	msg["36"] = New("36", "Unknown unique group %s")
+	msg["37"] = New("37", "Model doesn't support soft delete")
+	msg["38"] = New("38", "Row is referenced by %s")
+	msg["39"] = New("39", "Value %s already exists")
+	msg["40"] = New("40", "Value is required")
+	msg["41"] = New("41", "Value didn't pass %s check")
+	msg["42"] = New("42", "Value %s doesn't reference an existing row")
+	msg["43"] = New("43", "Constraint %s violated")
+	msg["44"] = New("44", "Row was modified by someone else")
+	msg["45"] = New("45", "If-Match header is required")
+	msg["46"] = New("46", "Invalid aggregate %s")
+	msg["47"] = New("47", "Unknown column %s")
+	msg["48"] = New("48", "Invalid %s tag on %s.%s")
+	msg["49"] = New("49", "Table %s doesn't exist")
+	msg["50"] = New("50", "Column %s doesn't exist")
+	msg["51"] = New("51", "Column %s is nullable")
+	msg["52"] = New("52", "Column type %s doesn't fit the field")
+	msg["53"] = New("53", "Primary key is (%s)")
+	msg["54"] = New("54", "Duplicate migration version %s")
+	msg["55"] = New("55", "Migration %s failed")
+	msg["56"] = New("56", "Migration %s can't be reverted")
+	msg["57"] = New("57", "Unknown migration %s")
+	msg["58"] = New("58", "Invalid migration file %s")
+	msg["59"] = New("59", "Unknown migration command %s")
+	msg["60"] = New("60", "Request body exceeds %s bytes")
+	msg["61"] = New("61", "Response exceeds %s bytes")
+	msg["62"] = New("62", "Primary key %s is missing")
+	msg["63"] = New("63", "Unknown operator %s")
+	msg["64"] = New("64", "Unsupported format %s")
 }
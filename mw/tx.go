@@ -0,0 +1,170 @@
+package mw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/config"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/msg"
+)
+
+var (
+	errRollback = errors.New("rollback")
+	errOverflow = errors.New("response too large")
+	errOnce     = errors.New("serialization failure, the chain can't run again")
+)
+
+//txWriter holds the response back until the transaction is committed
+type txWriter struct {
+	gin.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (w *txWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *txWriter) WriteHeaderNow() {}
+
+func (w *txWriter) Write(b []byte) (int, error) {
+	if int64(w.body.Len()+len(b)) > w.limit {
+		w.overflow = true
+		return 0, errOverflow
+	}
+	return w.body.Write(b)
+}
+
+func (w *txWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *txWriter) Status() int {
+	return w.status
+}
+
+func (w *txWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *txWriter) Written() bool {
+	return false
+}
+
+func (w *txWriter) Flush() {}
+
+//reset drops whatever a previous attempt wrote
+func (w *txWriter) reset() {
+
+	h := w.ResponseWriter.Header()
+	for k := range h {
+		delete(h, k)
+	}
+	for k, v := range w.header {
+		h[k] = v
+	}
+	w.status = http.StatusOK
+	w.overflow = false
+	w.body.Reset()
+}
+
+func (w *txWriter) flush() {
+
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	w.ResponseWriter.Write(w.body.Bytes())
+}
+
+//Tx exported
+//Runs the handlers given within a request scoped transaction, see
+//db.WithTx, i.e.
+//
+//	r.POST("/orders", mw.Tx(auth, OrderController{}.Post))
+//
+//The transaction is committed if the response status is below 400,
+//rolled back otherwise. The response is held back until the commit
+//succeeds, so the handlers are run again on serialization failures.
+//They run in order until one aborts, and mustn't call c.Next. With no
+//handlers the rest of the chain runs within the transaction, once, as
+//gin can't run it again: serialization failures get a 500.
+//Request bodies and responses are buffered up to db.tx_buffer bytes,
+//8 MiB by default. Larger bodies get a 413, larger responses roll the
+//transaction back and get a 500.
+func Tx(handlers ...gin.HandlerFunc) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		var (
+			limit   = int64(8 << 20)
+			attempt int
+		)
+
+		if config.Config().IsSet("db.tx_buffer") {
+			limit = config.Config().GetInt64("db.tx_buffer")
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+		if err != nil {
+			abort(c, http.StatusBadRequest, msg.Get("25").SetArgs(err.Error())) //Server error: %s
+			return
+		} else if int64(len(body)) > limit {
+			//Request body exceeds %s bytes
+			abort(c, http.StatusRequestEntityTooLarge, msg.Get("60").SetArgs(strconv.FormatInt(limit, 10)))
+			return
+		}
+
+		w := &txWriter{ResponseWriter: c.Writer, header: c.Writer.Header().Clone(), limit: limit}
+		c.Writer = w
+		err = db.WithTx(c, func() error {
+			if attempt++; attempt > 1 && len(handlers) == 0 {
+				return errOnce
+			}
+			n := len(c.Errors)
+			w.reset()
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+			if len(handlers) == 0 {
+				c.Next()
+			}
+			for _, h := range handlers {
+				if h(c); c.IsAborted() {
+					break
+				}
+			}
+			if w.overflow {
+				return errOverflow
+			} else if err := db.SerializationFailure(c, n); err != nil && (len(handlers) == 0 || c.IsAborted()) {
+				//neither the chain nor aborted handlers run again
+				return errOnce
+			} else if err != nil {
+				return err
+			} else if w.status >= http.StatusBadRequest {
+				return errRollback
+			}
+			return nil
+		})
+		c.Writer = w.ResponseWriter
+
+		switch {
+		case err == errOverflow:
+			w.ResponseWriter.Header().Del("Content-Type")
+			//Response exceeds %s bytes
+			abort(c, 500, msg.Get("61").SetArgs(strconv.FormatInt(limit, 10)))
+		case err != nil && w.status < http.StatusBadRequest:
+			//the handler succeeded but the commit didn't
+			abort(c, 500, msg.Get("25").SetArgs(err.Error())) //Server error: %s
+		default:
+			w.flush()
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package mw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/zicare/go-rpg/config"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/msg"
+)
+
+func TestMain(m *testing.M) {
+
+	dir, err := os.MkdirTemp("", "go-rpg")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Mkdir(filepath.Join(dir, "config"), 0755)
+	os.WriteFile(filepath.Join(dir, "config", "test.json"), []byte(`{
+		"db": {"driver": "sqlite3", "name": "`+filepath.Join(dir, "test.db")+`", "tx_buffer": 16}
+	}`), 0644)
+
+	gin.SetMode(gin.TestMode)
+
+	if err := config.Init("test", dir); err != nil {
+		panic(err)
+	} else if err := msg.Init(nil); err != nil {
+		panic(err)
+	} else if err := db.Init(); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestTxRunsTheHandlersAgain(t *testing.T) {
+
+	var (
+		r        = gin.New()
+		handled  int
+		after    int
+		response = "ok"
+	)
+
+	r.POST("/", Tx(
+		func(c *gin.Context) {
+			after++
+		},
+		func(c *gin.Context) {
+			if handled++; handled == 1 {
+				//as db queries report them
+				e := new(db.SerializationError)
+				e.Copy(msg.Get("25"))
+				c.Error(e)
+			}
+			c.String(http.StatusOK, response)
+		},
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("{}")))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("got %d %q, want 200 ok", w.Code, w.Body.String())
+	} else if handled != 2 || after != 2 {
+		t.Errorf("handler ran %d times, middleware %d, want 2 and 2", handled, after)
+	}
+
+	//over db.tx_buffer
+	response = strings.Repeat("x", 17)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("large response: got %d, want 500", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(response)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body: got %d, want 413", w.Code)
+	}
+}
+
+func TestTxRunsTheChainOnce(t *testing.T) {
+
+	var (
+		r       = gin.New()
+		handled int
+	)
+
+	r.POST("/", Tx(), func(c *gin.Context) {
+		handled++
+		e := new(db.SerializationError)
+		e.Copy(msg.Get("25"))
+		c.Error(e)
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if w.Code != http.StatusInternalServerError || handled != 1 {
+		t.Errorf("got %d, handler ran %d times, want 500 and once", w.Code, handled)
+	}
+}
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/msg"
+	"github.com/zicare/go-rpg/validation"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//PostMany exported
+func (ctrl Controller) PostMany(c *gin.Context, m db.Model) {
+
+	results, err := db.InsertMany(c, m)
+	bulk(c, results, err, http.StatusCreated)
+}
+
+//PutMany exported
+func (ctrl Controller) PutMany(c *gin.Context, m db.Model) {
+
+	results, err := db.UpdateMany(c, m)
+	bulk(c, results, err, http.StatusOK)
+}
+
+//DeleteMany exported
+func (ctrl Controller) DeleteMany(c *gin.Context, m db.Model) {
+
+	results, err := db.DeleteMany(c, m)
+	bulk(c, results, err, http.StatusNoContent)
+}
+
+//bulk responds with the status of every item, done is the
+//status of the items written
+func bulk(c *gin.Context, results []db.BulkResult, err error, done int) {
+
+	var items = []gin.H{}
+
+	for _, r := range results {
+		switch e := r.Err.(type) {
+		case nil:
+			if err != nil {
+				//rolled back due to another item
+				items = append(items, gin.H{"status": http.StatusFailedDependency})
+			} else if done == http.StatusNoContent {
+				items = append(items, gin.H{"status": done})
+			} else {
+				items = append(items, gin.H{"status": done, "data": r.Model.Xfrm(c)})
+			}
+		case *db.NotFoundError:
+			if done == http.StatusCreated {
+				//created but out of the read scope
+				items = append(items, gin.H{"status": http.StatusNoContent})
+			} else {
+				items = append(items, gin.H{"status": http.StatusNotFound, "message": e})
+			}
+		case validator.ValidationErrors, *time.ParseError, *json.UnmarshalTypeError:
+			items = append(items, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": msg.Get("19"), //There are validation errors
+				"errors":  validation.GetMessages(e, r.Model),
+			})
+		case *db.ConstraintError:
+			items = append(items, gin.H{
+				"status":  constraintStatus(e),
+				"message": msg.Get("19"), //There are validation errors
+				"errors":  e.Messages,
+			})
+		default:
+			items = append(items, gin.H{"status": status(e), "message": e})
+		}
+	}
+
+	switch {
+	case err == nil && done == http.StatusCreated:
+		c.JSON(http.StatusCreated, items)
+	case err == nil:
+		c.JSON(http.StatusOK, items)
+	case len(items) == 0:
+		c.JSON(status(err), gin.H{"message": err})
+	default:
+		c.JSON(status(err), gin.H{"message": err, "items": items})
+	}
+}
+
+//status returns the response status for err
+func status(err error) int {
+
+	switch e := err.(type) {
+	case *db.ConstraintError:
+		return constraintStatus(e)
+	case *db.ParamError, *db.NotAllowedError:
+		return http.StatusBadRequest
+	case validator.ValidationErrors, *time.ParseError, *json.UnmarshalTypeError:
+		return http.StatusBadRequest
+	case *db.NotFoundError:
+		return http.StatusNotFound
+	case *db.ConflictError:
+		return http.StatusConflict
+	case *db.PreconditionError:
+		return http.StatusPreconditionFailed
+	case *db.PreconditionRequiredError:
+		return http.StatusPreconditionRequired
+	case *db.TimeoutError:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,66 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/db"
+)
+
+//etag sets the ETag header to the version of m, if any
+func etag(c *gin.Context, m db.Model) {
+
+	if tag := db.ETag(m); tag != "" {
+		c.Header("ETag", tag)
+	}
+}
+
+//validators sets the ETag and Last-Modified headers and tells if the
+//client copy is still fresh, then the response is 304 Not Modified.
+//The ETag names the format of the response, which varies with Accept.
+//Collections have no Last-Modified, as the latest update of their rows
+//misses rows deleted, their ETag is a digest of the whole response.
+func validators(c *gin.Context, tag string, mod time.Time) bool {
+
+	tag = tagged(c, tag)
+	c.Header("Vary", "Accept")
+	c.Header("ETag", tag)
+	if !mod.IsZero() {
+		c.Header("Last-Modified", mod.UTC().Format(http.TimeFormat))
+	}
+
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	} else if inm := c.GetHeader("If-None-Match"); inm != "" {
+		//If-Modified-Since is ignored when If-None-Match is sent
+		for _, t := range strings.Split(inm, ",") {
+			//weak comparison
+			if t = strings.TrimPrefix(strings.TrimSpace(t), "W/"); t == "*" || t == tag {
+				return true
+			}
+		}
+		return false
+	} else if ims, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !mod.IsZero() {
+		return !mod.Truncate(time.Second).After(ims)
+	}
+	return false
+}
+
+//notModified responds 304 if the client copy of m, represented as out,
+//is still fresh. The entity tag is a digest of out, as ?embed=, ?cols=
+//and Xfrm shape it, preceded by the version of m and a +, if any.
+func notModified(c *gin.Context, m db.Model, out db.Model) bool {
+
+	tag := db.Digest(out.Val())
+	if v := db.ETag(m); v != "" {
+		tag = v[:len(v)-1] + "+" + tag[1:]
+	}
+
+	if validators(c, tag, db.LastModified(m)) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return true
+	}
+	return false
+}
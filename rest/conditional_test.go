@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/lib"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+type doc struct {
+	ID      *int64   `db:"id"      json:"id"      primary:"1"`
+	Version *int64   `db:"version" json:"version" version:"1"`
+	Tags    []string `db:"-"       json:"tags"`
+}
+
+func (*doc) New() db.Model {
+	return new(doc)
+}
+
+func (*doc) Table() string {
+	return "docs"
+}
+
+func (*doc) View() string {
+	return "docs"
+}
+
+func (d *doc) Val() interface{} {
+	return *d
+}
+
+func (d *doc) Xfrm(c *gin.Context) db.Model {
+	return d
+}
+
+func (d *doc) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*doc) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*doc) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return db.ErrDefaultDelete
+}
+
+func (*doc) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+func TestNotModified(t *testing.T) {
+
+	var (
+		id, version = int64(1), int64(3)
+		m           = &doc{ID: &id, Version: &version, Tags: []string{"a"}}
+		get         = func(inm string) (*gin.Context, *httptest.ResponseRecorder) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/docs/1", nil)
+			if inm != "" {
+				c.Request.Header.Set("If-None-Match", inm)
+			}
+			return c, w
+		}
+	)
+
+	c, _ := get("")
+	if notModified(c, m, m) {
+		t.Fatal("304 with no If-None-Match")
+	}
+	tag := c.Writer.Header().Get("ETag")
+
+	//the version, for If-Match, then the digest
+	if tag[:3] != `"3+` {
+		t.Errorf("ETag %s doesn't start with the version", tag)
+	}
+
+	c, w := get(tag)
+	if !notModified(c, m, m) || w.Code != http.StatusNotModified {
+		t.Errorf("got %d, want 304", w.Code)
+	}
+
+	//an embedded relation changed, the version didn't
+	m.Tags = append(m.Tags, "b")
+	if c, _ := get(tag); notModified(c, m, m) {
+		t.Error("304 for a changed representation")
+	}
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/lib"
+	"github.com/zicare/go-rpg/msg"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//product json names differ from its columns
+type product struct {
+	ID      *int64   `db:"id"       json:"id"    primary:"1" serial:"1"`
+	SKU     *string  `db:"sku"      json:"code"`
+	Label   *string  `db:"label"    json:"title"`
+	Price   *float64 `db:"price"    json:"cost"`
+	ShelfID *int64   `db:"shelf_id" json:"shelf" fk:"shelves.id"`
+}
+
+func (*product) New() db.Model {
+	return new(product)
+}
+
+func (*product) Table() string {
+	return "products"
+}
+
+func (*product) View() string {
+	return "products"
+}
+
+func (p *product) Val() interface{} {
+	return *p
+}
+
+func (p *product) Xfrm(c *gin.Context) db.Model {
+	return p
+}
+
+func (p *product) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*product) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*product) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return db.ErrDefaultDelete
+}
+
+func (*product) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+func TestPostConstraints(t *testing.T) {
+
+	schema(t,
+		`CREATE TABLE shelves (id INTEGER PRIMARY KEY)`,
+		`CREATE TABLE products (
+			id INTEGER PRIMARY KEY,
+			sku TEXT UNIQUE,
+			label TEXT NOT NULL,
+			price REAL CONSTRAINT price_positive CHECK (price > 0),
+			shelf_id INTEGER REFERENCES shelves(id)
+		)`,
+		`INSERT INTO shelves (id) VALUES (1)`,
+		`INSERT INTO products (sku, label, price, shelf_id) VALUES ('a1', 'Pen', 2, 1)`,
+	)
+	db.Register(new(product))
+
+	r := gin.New()
+	r.POST("/products", func(c *gin.Context) {
+		new(Controller).Post(c, new(product))
+	})
+
+	for _, tc := range []struct {
+		name   string
+		body   string
+		status int
+		field  string
+		key    string
+	}{
+		{"unique", `{"code": "a1", "title": "Ink", "cost": 1, "shelf": 1}`, http.StatusConflict, "code", "39"},
+		{"not null", `{"code": "b1", "cost": 1, "shelf": 1}`, http.StatusBadRequest, "title", "40"},
+		{"check", `{"code": "b1", "title": "Ink", "cost": -1, "shelf": 1}`, http.StatusBadRequest, "cost", "41"},
+		{"fk", `{"code": "b1", "title": "Ink", "cost": 1, "shelf": 9}`, http.StatusConflict, "shelf", "42"},
+	} {
+		var (
+			w   = httptest.NewRecorder()
+			req = httptest.NewRequest("POST", "/products", strings.NewReader(tc.body))
+			res struct {
+				Errors []msg.Message `json:"errors"`
+			}
+		)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, w.Code, tc.status, w.Body)
+			continue
+		} else if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		} else if len(res.Errors) != 1 || res.Errors[0].Field != tc.field || res.Errors[0].Key != tc.key {
+			t.Errorf("%s: errors %+v, want message %s on %s", tc.name, res.Errors, tc.key, tc.field)
+		}
+	}
+}
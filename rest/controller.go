@@ -3,6 +3,7 @@ package rest
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,21 +25,51 @@ func (ctrl Controller) Error() error {
 }
 
 //Index exported
+//Large collections are streamed, see db.Streaming
 func (ctrl Controller) Index(c *gin.Context, m db.Model) {
 
+	if unsupported(c) {
+		return
+	} else if db.Streaming(c, m) {
+		ctrl.stream(c, m)
+		return
+	}
+
 	if meta, data, err := db.FetchAll(c, m); err != nil {
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"message": err},
-		)
+		switch e := err.(type) {
+		case *db.ParamError:
+			c.JSON(
+				http.StatusBadRequest,
+				gin.H{"message": e},
+			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": e},
+			)
+		default:
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"message": e},
+			)
+		}
 	} else if len(data) <= 0 {
 		c.JSON(
 			http.StatusNotFound,
 			gin.H{"message": msg.Get("18")}, //Not found!
 		)
+	} else if validators(c, meta.ETag, time.Time{}) {
+		c.AbortWithStatus(http.StatusNotModified)
 	} else {
 		c.Header("X-Range", meta.Range)
 		c.Header("X-Checksum", meta.Checksum)
+		if meta.Next != "" {
+			c.Header("X-Next", meta.Next)
+		}
+		if format(c) != "json" {
+			render(c, http.StatusOK, m, m.Table(), true, data...)
+			return
+		}
 		c.JSON(http.StatusOK, func() []interface{} {
 			for k, v := range data {
 				data[k] = v
@@ -52,27 +83,74 @@ func (ctrl Controller) Index(c *gin.Context, m db.Model) {
 func (ctrl Controller) IndexHead(c *gin.Context, m db.Model) {
 
 	if meta, data, err := db.FetchAll(c, m); err != nil {
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"message": err},
-		)
+		switch e := err.(type) {
+		case *db.ParamError:
+			c.JSON(
+				http.StatusBadRequest,
+				gin.H{"message": e},
+			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": e},
+			)
+		default:
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"message": e},
+			)
+		}
 	} else if len(data) <= 0 {
 		c.JSON(
 			http.StatusNotFound,
 			gin.H{"message": msg.Get("18")}, //Not found!
 		)
+	} else if validators(c, meta.ETag, time.Time{}) {
+		c.AbortWithStatus(http.StatusNotModified)
 	} else {
 		c.Header("X-Range", meta.Range)
 		c.Header("X-Checksum", meta.Checksum)
+		if meta.Next != "" {
+			c.Header("X-Next", meta.Next)
+		}
 		c.JSON(http.StatusOK, gin.H{})
 	}
 
 }
 
+//Aggregate exported
+//Responds the grouped aggregates of the collection, i.e. on a /_agg route
+func (ctrl Controller) Aggregate(c *gin.Context, m db.Model) {
+
+	if data, err := db.Aggregate(c, m); err != nil {
+		switch e := err.(type) {
+		case *db.ParamError:
+			c.JSON(
+				http.StatusBadRequest,
+				gin.H{"message": e},
+			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": e},
+			)
+		default:
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"message": e},
+			)
+		}
+	} else {
+		c.JSON(http.StatusOK, data)
+	}
+}
+
 //Get exported
 func (ctrl Controller) Get(c *gin.Context, m db.Model) {
 
-	if err := db.Find(c, m); err != nil {
+	if unsupported(c) {
+		return
+	} else if err := db.Find(c, m); err != nil {
 		switch e := err.(type) {
 		case *db.NotFoundError:
 			c.JSON(
@@ -84,14 +162,24 @@ func (ctrl Controller) Get(c *gin.Context, m db.Model) {
 				http.StatusBadRequest,
 				gin.H{"message": e},
 			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": e},
+			)
 		default:
 			c.JSON(
 				http.StatusInternalServerError,
 				gin.H{"message": e},
 			)
 		}
+	} else if out := m.Xfrm(c); notModified(c, m, out) {
+		return
+	} else if format(c) != "json" {
+		name := m.Table() + "-" + strings.Replace(c.Param("id"), ",", "-", -1)
+		render(c, http.StatusOK, m, name, false, out)
 	} else {
-		c.JSON(http.StatusOK, m.Xfrm(c))
+		c.JSON(http.StatusOK, out)
 	}
 }
 
@@ -99,7 +187,7 @@ func (ctrl Controller) Get(c *gin.Context, m db.Model) {
 func (ctrl *Controller) Post(c *gin.Context, m db.Model) {
 
 	if ctrl.err = db.Insert(c, m); ctrl.err != nil {
-		switch ctrl.err.(type) {
+		switch e := ctrl.err.(type) {
 		case *db.NotFoundError:
 			//Resource created but out of the read scope
 			//so response is 204
@@ -114,6 +202,20 @@ func (ctrl *Controller) Post(c *gin.Context, m db.Model) {
 					"errors":  validation.GetMessages(ctrl.err, m),
 				},
 			)
+		case *db.ConstraintError:
+			//constraint violations
+			c.JSON(
+				constraintStatus(e),
+				gin.H{
+					"message": msg.Get("19"), //There are validation errors
+					"errors":  e.Messages,
+				},
+			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": ctrl.err},
+			)
 		default:
 			//Resource not created
 			//something went wrong but we don't know what
@@ -123,14 +225,24 @@ func (ctrl *Controller) Post(c *gin.Context, m db.Model) {
 			)
 		}
 	} else {
+		etag(c, m)
 		c.JSON(http.StatusCreated, m.Xfrm(c))
 	}
 }
 
 //Put exported
+//Models implementing db.UpsertModel may be created on PUT
 func (ctrl Controller) Put(c *gin.Context, m db.Model) {
 
-	if ctrl.err = db.Update(c, m); ctrl.err != nil {
+	var created bool
+
+	if um, ok := m.(db.UpsertModel); ok && um.CreateOnPut() {
+		created, ctrl.err = db.Upsert(c, m, "")
+	} else {
+		ctrl.err = db.Update(c, m)
+	}
+
+	if ctrl.err != nil {
 		switch e := ctrl.err.(type) {
 		case *db.ParamError:
 			//composite key missuse
@@ -139,6 +251,11 @@ func (ctrl Controller) Put(c *gin.Context, m db.Model) {
 				gin.H{"message": e},
 			)
 		case *db.NotFoundError:
+			if created {
+				//Resource created but out of the read scope
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
 			//not found or out of scope
 			c.JSON(
 				http.StatusNotFound,
@@ -153,13 +270,110 @@ func (ctrl Controller) Put(c *gin.Context, m db.Model) {
 					"errors":  validation.GetMessages(e, m),
 				},
 			)
+		case *db.ConstraintError:
+			//constraint violations
+			c.JSON(
+				constraintStatus(e),
+				gin.H{
+					"message": msg.Get("19"), //There are validation errors
+					"errors":  e.Messages,
+				},
+			)
+		case *db.PreconditionError:
+			//version mismatch
+			c.JSON(
+				http.StatusPreconditionFailed,
+				gin.H{"message": e},
+			)
+		case *db.PreconditionRequiredError:
+			c.JSON(
+				http.StatusPreconditionRequired,
+				gin.H{"message": e},
+			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": e},
+			)
 		default:
 			c.JSON(
 				http.StatusInternalServerError,
 				gin.H{"message": e},
 			)
 		}
+	} else if created {
+		etag(c, m)
+		c.JSON(http.StatusCreated, m.Xfrm(c))
 	} else {
+		etag(c, m)
+		c.JSON(http.StatusOK, m.Xfrm(c))
+	}
+}
+
+//Patch exported
+func (ctrl Controller) Patch(c *gin.Context, m db.Model) {
+
+	if ctrl.err = db.Patch(c, m); ctrl.err != nil {
+		switch e := ctrl.err.(type) {
+		case *db.ParamError:
+			//composite key missuse or malformed patch
+			c.JSON(
+				http.StatusBadRequest,
+				gin.H{"message": e},
+			)
+		case *db.MediaTypeError:
+			c.JSON(
+				http.StatusUnsupportedMediaType,
+				gin.H{"message": e},
+			)
+		case *db.NotFoundError:
+			//not found or out of scope
+			c.JSON(
+				http.StatusNotFound,
+				gin.H{"message": e},
+			)
+		case validator.ValidationErrors, *time.ParseError, *json.UnmarshalTypeError:
+			//payload issues
+			c.JSON(
+				http.StatusBadRequest,
+				gin.H{
+					"message": msg.Get("19"), //There are validation errors
+					"errors":  validation.GetMessages(e, m),
+				},
+			)
+		case *db.ConstraintError:
+			//constraint violations
+			c.JSON(
+				constraintStatus(e),
+				gin.H{
+					"message": msg.Get("19"), //There are validation errors
+					"errors":  e.Messages,
+				},
+			)
+		case *db.PreconditionError:
+			//version mismatch
+			c.JSON(
+				http.StatusPreconditionFailed,
+				gin.H{"message": e},
+			)
+		case *db.PreconditionRequiredError:
+			c.JSON(
+				http.StatusPreconditionRequired,
+				gin.H{"message": e},
+			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": e},
+			)
+		default:
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"message": e},
+			)
+		}
+	} else {
+		etag(c, m)
 		c.JSON(http.StatusOK, m.Xfrm(c))
 	}
 }
@@ -175,7 +389,7 @@ func (ctrl Controller) Delete(c *gin.Context, m db.Model) {
 			http.StatusBadRequest,
 			gin.H{"message": ctrl.err},
 		)
-	} else if ctrl.err = m.Delete(c, pIDs); ctrl.err != nil {
+	} else if ctrl.err = db.Remove(c, m, pIDs); ctrl.err != nil {
 		switch e := ctrl.err.(type) {
 		case *db.NotAllowedError:
 			c.JSON(
@@ -192,6 +406,22 @@ func (ctrl Controller) Delete(c *gin.Context, m db.Model) {
 				http.StatusConflict,
 				gin.H{"message": e},
 			)
+		case *db.PreconditionError:
+			//version mismatch
+			c.JSON(
+				http.StatusPreconditionFailed,
+				gin.H{"message": e},
+			)
+		case *db.PreconditionRequiredError:
+			c.JSON(
+				http.StatusPreconditionRequired,
+				gin.H{"message": e},
+			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": e},
+			)
 		default:
 			c.JSON(
 				http.StatusInternalServerError,
@@ -203,3 +433,53 @@ func (ctrl Controller) Delete(c *gin.Context, m db.Model) {
 		c.AbortWithStatus(http.StatusNoContent)
 	}
 }
+
+//Restore exported
+//Brings back a soft deleted row
+func (ctrl Controller) Restore(c *gin.Context, m db.Model) {
+
+	if ctrl.err = db.Restore(c, m); ctrl.err != nil {
+		switch e := ctrl.err.(type) {
+		case *db.ParamError:
+			//composite key missuse or no soft delete
+			c.JSON(
+				http.StatusBadRequest,
+				gin.H{"message": e},
+			)
+		case *db.NotAllowedError:
+			c.JSON(
+				http.StatusForbidden,
+				gin.H{"message": e},
+			)
+		case *db.NotFoundError:
+			//not found, not deleted or out of scope
+			c.JSON(
+				http.StatusNotFound,
+				gin.H{"message": e},
+			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": e},
+			)
+		default:
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"message": e},
+			)
+		}
+	} else {
+		etag(c, m)
+		c.JSON(http.StatusOK, m.Xfrm(c))
+	}
+}
+
+//constraintStatus returns 409 for rows clashing with others,
+//400 for invalid values
+func constraintStatus(e *db.ConstraintError) int {
+
+	if e.Conflict() {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,213 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/msg"
+)
+
+//format returns the output format requested, json, csv or ndjson,
+//by ?format= or else by the Accept header
+func format(c *gin.Context) string {
+
+	switch f := c.Query("format"); f {
+	case "json", "csv", "ndjson":
+		return f
+	}
+
+	accept := c.GetHeader("Accept")
+	switch {
+	case strings.Contains(accept, "text/csv"):
+		return "csv"
+	case strings.Contains(accept, "application/x-ndjson"):
+		return "ndjson"
+	}
+	return "json"
+}
+
+//unsupported responds 406 if ?format= names none of json, csv or
+//ndjson, telling if it did
+func unsupported(c *gin.Context) bool {
+
+	switch f := c.Query("format"); f {
+	case "", "json", "csv", "ndjson":
+		return false
+	default:
+		c.JSON(
+			http.StatusNotAcceptable,
+			gin.H{"message": msg.Get("64").SetArgs(f)}, //Unsupported format %s
+		)
+		return true
+	}
+}
+
+//tagged returns the entity tag of the representation in the format
+//requested, tag itself for JSON
+func tagged(c *gin.Context, tag string) string {
+
+	if f := format(c); f != "json" && len(tag) > 1 {
+		return tag[:len(tag)-1] + "-" + f + `"`
+	}
+	return tag
+}
+
+//disposition returns the Content-Disposition of an attachment named name,
+//stripped of what could break out of the header or the download directory
+func disposition(name string) string {
+
+	name = strings.Map(func(r rune) rune {
+		if r == '"' || r == '\\' || r == '/' || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	if d := mime.FormatMediaType("attachment", map[string]string{"filename": name}); d != "" {
+		return d
+	}
+	return "attachment"
+}
+
+//encoder writes rows as a JSON array, NDJSON or CSV. CSV columns
+//are the json names of the columns selected, values holding objects
+//or arrays are written as JSON.
+type encoder struct {
+	format string
+	cols   []string
+	w      gin.ResponseWriter
+	csv    *csv.Writer
+	n      int
+}
+
+//newEncoder returns the encoder for the format requested, setting
+//the response Content-Type and, but for JSON, Content-Disposition
+//with the file name given
+func newEncoder(c *gin.Context, m db.Model, name string) *encoder {
+
+	e := &encoder{format: format(c), w: c.Writer}
+
+	switch e.format {
+	case "csv":
+		e.cols = db.Columns(c, m)
+		e.csv = csv.NewWriter(c.Writer)
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", disposition(name+".csv"))
+	case "ndjson":
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", disposition(name+".ndjson"))
+	default:
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	return e
+}
+
+//open writes what goes before the rows
+func (e *encoder) open(array bool) error {
+
+	switch {
+	case e.format == "csv":
+		return e.csv.Write(e.cols)
+	case e.format == "json" && array:
+		_, err := e.w.WriteString("[")
+		return err
+	}
+	return nil
+}
+
+//write writes the row v
+func (e *encoder) write(v interface{}) error {
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	defer func() { e.n++ }()
+
+	switch e.format {
+	case "csv":
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(b, &obj); err != nil {
+			return err
+		}
+		record := make([]string, len(e.cols))
+		for i, k := range e.cols {
+			record[i] = cell(obj[k])
+		}
+		return e.csv.Write(record)
+	case "ndjson":
+		b = append(b, '\n')
+	default:
+		if e.n > 0 {
+			b = append([]byte(","), b...)
+		}
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+//flush sends the rows written so far
+func (e *encoder) flush() error {
+
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return nil
+}
+
+//close writes what goes after the rows
+func (e *encoder) close(array bool) error {
+
+	if e.format == "json" && array {
+		if _, err := e.w.WriteString("]"); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+//cell returns the CSV value of a JSON value, strings unquoted,
+//null empty and any other as JSON
+func cell(raw json.RawMessage) string {
+
+	var s string
+
+	if raw = bytes.TrimSpace(raw); len(raw) == 0 || string(raw) == "null" {
+		return ""
+	} else if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+//render writes rows with the status code in the format requested,
+//JSON ones as an array if array is set
+func render(c *gin.Context, code int, m db.Model, name string, array bool, rows ...interface{}) {
+
+	enc := newEncoder(c, m, name)
+	c.Status(code)
+	if err := enc.open(array); err != nil {
+		c.Error(err)
+		return
+	}
+	for _, v := range rows {
+		if err := enc.write(v); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	if err := enc.close(array); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDisposition(t *testing.T) {
+
+	for name, want := range map[string]string{
+		"items-1.csv":                `attachment; filename=items-1.csv`,
+		"items-\"a\r\nb\\/..\\x.csv": `attachment; filename=items-ab..x.csv`,
+	} {
+		if got := disposition(name); got != want {
+			t.Errorf("disposition(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestTagged(t *testing.T) {
+
+	for accept, want := range map[string]string{
+		"application/json": `"1"`,
+		"text/csv":         `"1-csv"`,
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/items/1", nil)
+		c.Request.Header.Set("Accept", accept)
+		if validators(c, `"1"`, time.Time{}) || c.Writer.Header().Get("ETag") != want {
+			t.Errorf("ETag for %s = %s, want %s", accept, c.Writer.Header().Get("ETag"), want)
+		}
+		if v := c.Writer.Header().Get("Vary"); v != "Accept" {
+			t.Errorf("Vary = %s, want Accept", v)
+		}
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+
+	for target, want := range map[string]int{
+		"/items":             http.StatusOK,
+		"/items?format=csv":  http.StatusOK,
+		"/items?format=xml":  http.StatusNotAcceptable,
+		"/items/1?format=":   http.StatusOK,
+		"/items/1?format=md": http.StatusNotAcceptable,
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", target, nil)
+		if unsupported(c) != (want != http.StatusOK) || w.Code != want {
+			t.Errorf("%s: status %d, want %d", target, w.Code, want)
+		}
+	}
+}
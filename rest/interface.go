@@ -13,3 +13,9 @@ type ControllerInterface interface {
 	Put(c *gin.Context)
 	Delete(c *gin.Context)
 }
+
+//PatchControllerInterface exported
+//Controllers implementing it get the PATCH routes of Nest
+type PatchControllerInterface interface {
+	Patch(c *gin.Context)
+}
@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/zicare/go-rpg/config"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/msg"
+)
+
+//tests run against a sqlite db in a temporary directory
+func TestMain(m *testing.M) {
+
+	dir, err := os.MkdirTemp("", "go-rpg")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Mkdir(filepath.Join(dir, "config"), 0755)
+	os.WriteFile(filepath.Join(dir, "config", "test.json"), []byte(`{
+		"db": {"driver": "sqlite3", "name": "`+filepath.Join(dir, "test.db")+`?_busy_timeout=5000&_fk=1"}
+	}`), 0644)
+
+	gin.SetMode(gin.TestMode)
+
+	if err := config.Init("test", dir); err != nil {
+		panic(err)
+	} else if err := msg.Init(nil); err != nil {
+		panic(err)
+	} else if err := db.Init(); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+//schema runs the statements given on the test db
+func schema(t testing.TB, stmts ...string) {
+
+	t.Helper()
+	for _, q := range stmts {
+		if _, err := db.Db().Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/msg"
+)
+
+//Nest exported
+//Registers the routes of ctrl, the controller of child, under a parent
+//row, i.e. for path /customers/:id/orders
+//
+//	GET, HEAD, POST           /customers/:id/orders
+//	GET, PUT, PATCH, DELETE   /customers/:id/orders/:child
+//
+//PATCH is routed if ctrl is a PatchControllerInterface too. Child rows
+//are those whose fk column holds the parent id, and the fk column of
+//rows created is set to it. The parent is looked up within
+//its scope first, responding 404 if it's not found. Handlers run before
+//the ones of ctrl, mw.Tx included, which then covers the parent lookup.
+func Nest(r gin.IRouter, path string, parent db.Model, child db.Model, fk string, ctrl ControllerInterface, handlers ...gin.HandlerFunc) {
+
+	var (
+		g    = r.Group(path, handlers...)
+		nest = func(h gin.HandlerFunc) gin.HandlerFunc {
+			return nested(parent, child, fk, h)
+		}
+	)
+
+	g.GET("", nest(ctrl.Index))
+	g.HEAD("", nest(ctrl.IndexHead))
+	g.POST("", nest(ctrl.Post))
+	g.GET("/:child", nest(ctrl.Get))
+	g.PUT("/:child", nest(ctrl.Put))
+	if p, ok := ctrl.(PatchControllerInterface); ok {
+		g.PATCH("/:child", nest(p.Patch))
+	}
+	g.DELETE("/:child", nest(ctrl.Delete))
+}
+
+//nested returns h running for the children of the parent row,
+//the :child param becomes the :id param for h
+func nested(parent db.Model, child db.Model, fk string, h gin.HandlerFunc) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		var (
+			p      = parent.New()
+			id, e1 = db.ParamIDs(c, p)
+		)
+
+		if e1 == nil && len(id) != 1 {
+			//composite parent keys aren't supported
+			e := new(db.ParamError)
+			e.Copy(msg.Get("26")) //Composite key missuse
+			e1 = e
+		}
+
+		if e1 != nil {
+			c.AbortWithStatusJSON(
+				http.StatusBadRequest,
+				gin.H{"message": e1},
+			)
+			return
+		} else if err := db.ByID(c, p, id); err != nil {
+			switch e := err.(type) {
+			case *db.NotFoundError:
+				//parent not found or out of scope
+				c.AbortWithStatusJSON(
+					http.StatusNotFound,
+					gin.H{"message": e},
+				)
+			case *db.TimeoutError:
+				c.AbortWithStatusJSON(
+					http.StatusGatewayTimeout,
+					gin.H{"message": e},
+				)
+			default:
+				c.AbortWithStatusJSON(
+					http.StatusInternalServerError,
+					gin.H{"message": e},
+				)
+			}
+			return
+		}
+
+		c.Set("Parent", db.Parent{
+			Table: child.Table(),
+			Col:   fk,
+			Val:   id[0].B.(string),
+		})
+
+		//the child id takes the place of the parent one
+		params := gin.Params{}
+		for _, param := range c.Params {
+			if param.Key == "child" {
+				params = append(params, gin.Param{Key: "id", Value: param.Value})
+			} else if param.Key != "id" {
+				params = append(params, param)
+			}
+		}
+		c.Params = params
+
+		h(c)
+	}
+}
@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/lib"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+//board has cards
+type board struct {
+	ID *int64 `db:"id" json:"id" primary:"1"`
+}
+
+func (*board) New() db.Model {
+	return new(board)
+}
+
+func (*board) Table() string {
+	return "boards"
+}
+
+func (*board) View() string {
+	return "boards"
+}
+
+func (b *board) Val() interface{} {
+	return *b
+}
+
+func (b *board) Xfrm(c *gin.Context) db.Model {
+	return b
+}
+
+func (b *board) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*board) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*board) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return db.ErrDefaultDelete
+}
+
+func (*board) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+type card struct {
+	ID      *int64  `db:"id"       json:"id"    primary:"1" serial:"1"`
+	BoardID *int64  `db:"board_id" json:"board" fk:"boards.id"`
+	Title   *string `db:"title"    json:"title"`
+}
+
+func (*card) New() db.Model {
+	return new(card)
+}
+
+func (*card) Table() string {
+	return "cards"
+}
+
+func (*card) View() string {
+	return "cards"
+}
+
+func (k *card) Val() interface{} {
+	return *k
+}
+
+func (k *card) Xfrm(c *gin.Context) db.Model {
+	return k
+}
+
+func (k *card) Bind(c *gin.Context, pIDs []lib.Pair) error {
+	return nil
+}
+
+func (*card) Validation(v *validator.Validate, sl *validator.StructLevel) {}
+
+func (*card) Delete(c *gin.Context, pIDs []lib.Pair) error {
+	return db.ErrDefaultDelete
+}
+
+func (*card) Scope(b sqlbuilder.Builder, c *gin.Context) {}
+
+type cardController struct {
+	Controller
+}
+
+func (ctrl cardController) Index(c *gin.Context) {
+	ctrl.Controller.Index(c, new(card))
+}
+
+func (ctrl cardController) IndexHead(c *gin.Context) {
+	ctrl.Controller.IndexHead(c, new(card))
+}
+
+func (ctrl cardController) Get(c *gin.Context) {
+	ctrl.Controller.Get(c, new(card))
+}
+
+func (ctrl cardController) Post(c *gin.Context) {
+	ctrl.Controller.Post(c, new(card))
+}
+
+func (ctrl cardController) Put(c *gin.Context) {
+	ctrl.Controller.Put(c, new(card))
+}
+
+func (ctrl cardController) Patch(c *gin.Context) {
+	ctrl.Controller.Patch(c, new(card))
+}
+
+func (ctrl cardController) Delete(c *gin.Context) {
+	ctrl.Controller.Delete(c, new(card))
+}
+
+func TestNest(t *testing.T) {
+
+	schema(t,
+		`CREATE TABLE boards (id INTEGER PRIMARY KEY)`,
+		`CREATE TABLE cards (id INTEGER PRIMARY KEY AUTOINCREMENT, board_id INTEGER NOT NULL REFERENCES boards(id), title TEXT)`,
+		`INSERT INTO boards (id) VALUES (1), (2)`,
+	)
+
+	r := gin.New()
+	Nest(r, "/boards/:id/cards", new(board), new(card), "board_id", cardController{})
+
+	for _, tc := range []struct {
+		method string
+		target string
+		body   string
+		status int
+		want   card
+	}{
+		//missing parent
+		{"GET", "/boards/9/cards", "", http.StatusNotFound, card{}},
+		{"POST", "/boards/9/cards", `{"title": "a"}`, http.StatusNotFound, card{}},
+		//the fk is the parent id, whatever the payload holds
+		{"POST", "/boards/1/cards", `{"title": "a", "board": 2}`, http.StatusCreated, card{BoardID: i64(1), Title: str("a")}},
+		{"PATCH", "/boards/1/cards/1", `{"title": "b"}`, http.StatusOK, card{BoardID: i64(1), Title: str("b")}},
+		{"PATCH", "/boards/2/cards/1", `{"title": "c"}`, http.StatusNotFound, card{}},
+		{"GET", "/boards/1/cards/1", "", http.StatusOK, card{BoardID: i64(1), Title: str("b")}},
+	} {
+		var (
+			w   = httptest.NewRecorder()
+			req = httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			got card
+		)
+		if tc.method == "PATCH" {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		} else if tc.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s: status %d, want %d: %s", tc.method, tc.target, w.Code, tc.status, w.Body)
+		} else if tc.want.Title == nil {
+			continue
+		} else if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s %s: %v", tc.method, tc.target, err)
+		} else if got.BoardID == nil || *got.BoardID != *tc.want.BoardID || got.Title == nil || *got.Title != *tc.want.Title {
+			t.Errorf("%s %s: got %s", tc.method, tc.target, w.Body)
+		}
+	}
+}
+
+func i64(v int64) *int64 {
+	return &v
+}
+
+func str(s string) *string {
+	return &s
+}
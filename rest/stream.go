@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zicare/go-rpg/db"
+	"github.com/zicare/go-rpg/msg"
+)
+
+//stream writes the collection as it's scanned, flushing every chunk,
+//in the format requested. The cursor to the next page and the checksum
+//are sent in the X-Next and X-Checksum trailers. There's no ETag, it'd
+//take the whole response. Failures once the response started can only
+//cut it short.
+func (ctrl Controller) stream(c *gin.Context, m db.Model) {
+
+	var enc *encoder
+
+	head := func(meta db.ResultSetMeta) error {
+		c.Header("X-Range", meta.Range)
+		c.Header("Vary", "Accept")
+		c.Header("Trailer", "X-Next, X-Checksum")
+		enc = newEncoder(c, m, m.Table())
+		c.Status(http.StatusOK)
+		c.Writer.WriteHeaderNow()
+		return enc.open(true)
+	}
+
+	emit := func(rows []interface{}) error {
+		for _, v := range rows {
+			if err := enc.write(v); err != nil {
+				//client gone
+				return err
+			}
+		}
+		return enc.flush()
+	}
+
+	if meta, err := db.Stream(c, m, head, emit); err != nil && c.Writer.Written() {
+		//cut short
+		c.Error(err)
+	} else if err != nil {
+		switch e := err.(type) {
+		case *db.ParamError:
+			c.JSON(
+				http.StatusBadRequest,
+				gin.H{"message": e},
+			)
+		case *db.TimeoutError:
+			c.JSON(
+				http.StatusGatewayTimeout,
+				gin.H{"message": e},
+			)
+		default:
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"message": e},
+			)
+		}
+	} else if !c.Writer.Written() {
+		c.JSON(
+			http.StatusNotFound,
+			gin.H{"message": msg.Get("18")}, //Not found!
+		)
+	} else {
+		enc.close(true)
+		if meta.Next != "" {
+			c.Header("X-Next", meta.Next)
+		}
+		c.Header("X-Checksum", meta.Checksum)
+	}
+}
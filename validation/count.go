@@ -4,7 +4,6 @@ import (
 	"reflect"
 	"strings"
 
-	"github.com/huandu/go-sqlbuilder"
 	"github.com/zicare/go-rpg/db"
 	"gopkg.in/go-playground/validator.v8"
 )
@@ -17,7 +16,9 @@ func Count(
 
 	var (
 		f     = strings.Split(param, ".")
-		sb    = sqlbuilder.PostgreSQL.NewSelectBuilder()
+		m, _  = topStruct.Interface().(db.Model)
+		c     = db.Request(m)
+		sb    = db.Flavor().NewSelectBuilder()
 		count = 0
 	)
 
@@ -25,9 +26,12 @@ func Count(
 	sb.From(f[0])
 	sb.Where(sb.Equal(f[1], field.Interface()))
 
+	ctx, cancel := db.Context(c, nil)
+	defer cancel()
+
 	sql, args := sb.Build()
 	//fmt.Println(sql, args)
-	if err := db.Db().QueryRow(sql, args...).Scan(&count); err != nil || count < 1 {
+	if err := db.Conn(c).QueryRowContext(ctx, sql, args...).Scan(&count); err != nil || count < 1 {
 		return false
 	}
 	return true
@@ -4,7 +4,6 @@ import (
 	"reflect"
 	"strings"
 
-	"github.com/huandu/go-sqlbuilder"
 	"github.com/zicare/go-rpg/db"
 	"github.com/zicare/go-rpg/slice"
 	"gopkg.in/go-playground/validator.v8"
@@ -19,31 +18,40 @@ func Unique(
 	//fmt.Println(currentStructOrField)
 
 	var (
-		f     = strings.Split(param, " ")
-		m     = currentStructOrField.Interface().(db.Model)
-		t     = reflect.Indirect(reflect.ValueOf(m))
-		sb    = sqlbuilder.PostgreSQL.NewSelectBuilder()
-		count = 0
+		f         = strings.Split(param, " ")
+		m         = currentStructOrField.Interface().(db.Model)
+		c         = db.Request(m)
+		fields, _ = db.Fields(m)
+		sb        = db.Flavor().NewSelectBuilder()
+		count     = 0
 	)
 
 	sb.Select(sb.As("COUNT(*)", "count"))
 	sb.From(m.Table())
 
-	for i := 0; i < t.NumField(); i++ {
-		if tag, ok := t.Type().Field(i).Tag.Lookup("db"); ok {
-			if fv, _, ok := v.GetStructFieldOK(currentStructOrField, t.Type().Field(i).Name); ok {
-				if pk, _ := t.Type().Field(i).Tag.Lookup("primary"); pk == "1" {
-					sb.Where(sb.NotEqual(tag, fv.Interface()))
-				} else if slice.Contains(f, tag) {
-					sb.Where(sb.Equal(tag, fv.Interface()))
-				}
+	for _, tag := range fields.Ordered {
+		if fv, _, ok := v.GetStructFieldOK(currentStructOrField, fields.Field[tag]); ok {
+			null := fv.Kind() == reflect.Ptr
+			if null && slice.Contains(fields.Primary, tag) {
+				//not a row yet
+				continue
+			} else if null && slice.Contains(f, tag) {
+				//NULL never clashes, neither does the group
+				return true
+			} else if slice.Contains(fields.Primary, tag) {
+				sb.Where(sb.NotEqual(tag, fv.Interface()))
+			} else if slice.Contains(f, tag) {
+				sb.Where(sb.Equal(tag, fv.Interface()))
 			}
 		}
 	}
 
+	ctx, cancel := db.Context(c, m)
+	defer cancel()
+
 	sql, args := sb.Build()
 	//fmt.Println(sql, args)
-	if err := db.Db().QueryRow(sql, args...).Scan(&count); err != nil || count > 0 {
+	if err := db.Conn(c).QueryRowContext(ctx, sql, args...).Scan(&count); err != nil || count > 0 {
 		return false
 	}
 	return true
@@ -59,7 +67,7 @@ func Unique(
 	var (
 		m     = currentStructOrField.Interface().(db.Model)
 		t     = reflect.Indirect(reflect.ValueOf(m))
-		sb    = sqlbuilder.PostgreSQL.NewSelectBuilder()
+		sb    = db.Flavor().NewSelectBuilder()
 		count = 0
 	)
 